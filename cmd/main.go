@@ -15,72 +15,148 @@
 package main
 
 import (
-	"bytes"
-	"io/ioutil"
+	"go/types"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
 	"github.com/alecthomas/kong"
 	"github.com/pkg/errors"
 
+	"github.com/muvaf/typewriter/internal/templates"
 	"github.com/muvaf/typewriter/pkg/cmd"
 	"github.com/muvaf/typewriter/pkg/packages"
+	twtypes "github.com/muvaf/typewriter/pkg/types"
 	"github.com/muvaf/typewriter/pkg/wrapper"
 )
 
+var conflictStrategies = map[string]twtypes.ConflictStrategy{
+	"error":        twtypes.ConflictError,
+	"prefer-first": twtypes.ConflictPreferFirst,
+	"prefer-last":  twtypes.ConflictPreferLast,
+	"rename":       twtypes.ConflictRename,
+}
+
+var fieldOrders = map[string]twtypes.FieldOrder{
+	"declaration":  twtypes.OrderDeclaration,
+	"alphabetical": twtypes.OrderAlphabetical,
+	"marker":       twtypes.OrderMarker,
+}
+
+var commentStrategies = map[string]twtypes.CommentMergeStrategy{
+	"first": twtypes.CommentMergeFirst,
+	"join":  twtypes.CommentMergeJoin,
+}
+
 type typeWriterCLI struct {
+	HeaderPath    string `help:"Path to the file whose content will be added to the top of the generated files." type:"path"`
+	DisableLinter bool   `help:"Option to disable linting the output. Useful for debugging errors."`
+
+	Producers producersCmd `cmd:"" help:"Generate producer functions of the types marked as aggregated."`
+	Aggregate aggregateCmd `cmd:"" help:"Generate the aggregate type of given types together with the aggregates of their nested types."`
+}
+
+type producersCmd struct {
 	PackagePath       string `help:"Path to package dir to scan" type:"path" required:""`
 	TargetPackagePath string `help:"Package to write the generated files. If not given, package path will be used." type:"path"`
-	DisableLinter     bool   `help:"Option to disable linting the output. Useful for debugging errors."`
+}
+
+func (p *producersCmd) Run(cli *typeWriterCLI) error {
+	targetPkgPath := p.TargetPackagePath
+	if targetPkgPath == "" {
+		targetPkgPath = p.PackagePath
+	}
+	return errors.Wrap(PrintProducers(p.PackagePath, targetPkgPath, cli.HeaderPath, !cli.DisableLinter), "cannot print producers")
+}
+
+type aggregateCmd struct {
+	Types             []string `name:"type" help:"Full path of the types to aggregate, i.e. github.com/org/repo/db.UserV1. Can be repeated." required:""`
+	Name              string   `help:"Name of the aggregate type. If not given, it's derived from the names of given types."`
+	TargetPackagePath string   `help:"Package to write the aggregate types." type:"path" required:""`
+	ConflictStrategy  string   `help:"What to do with the fields that have the same name but different types." enum:"error,prefer-first,prefer-last,rename" default:"error"`
+	FieldOrder        string   `help:"Order of the fields in the aggregate types." enum:"declaration,alphabetical,marker" default:"declaration"`
+	CommentStrategy   string   `help:"What to do with the different comments of the types and fields that are merged." enum:"first,join" default:"first"`
+	StripMarkers      []string `name:"strip-marker" help:"Marker that should not be carried to the aggregate types in <section>:<key> format, i.e. field:expr. Can be repeated."`
+}
+
+func (a *aggregateCmd) Run(cli *typeWriterCLI) error {
+	c := packages.NewCache()
+	ct := twtypes.NewCommentTransfer(packages.NewCommentCache(c),
+		twtypes.WithCommentMergeStrategy(commentStrategies[a.CommentStrategy]),
+		twtypes.WithMarkerFilter(twtypes.StripMarkers(a.StripMarkers...)))
+	err := PrintAggregates(c, a.Types, a.Name, a.TargetPackagePath, fieldOrders[a.FieldOrder], cli.HeaderPath, !cli.DisableLinter,
+		twtypes.WithConflictStrategy(conflictStrategies[a.ConflictStrategy]),
+		twtypes.WithMergeOrder(fieldOrders[a.FieldOrder]),
+		twtypes.WithMergeComments(ct))
+	return errors.Wrap(err, "cannot print aggregates")
 }
 
 func main() {
 	cli := &typeWriterCLI{}
 	ctx := kong.Parse(cli)
-	targetPackagePath := cli.TargetPackagePath
-	if targetPackagePath == "" {
-		targetPackagePath = cli.PackagePath
-	}
-	ctx.FatalIfErrorf(PrintProducers(cli.PackagePath, cli.TargetPackagePath, cli.DisableLinter), "cannot print producers")
+	ctx.FatalIfErrorf(ctx.Run(cli))
 }
 
-func PrintProducers(pkgPath, targetPkgPath string, disableLinter bool) error {
+func PrintProducers(pkgPath, targetPkgPath, headerPath string, lint bool) error {
 	c := packages.NewCache()
-	targetPkgName := targetPkgPath[strings.LastIndex(targetPkgPath, "/")+1:]
-	tmplPath := "/Users/monus/go/src/github.com/muvaf/typewriter/internal/templates/producers.go.tmpl"
-	headerPath := "/Users/monus/go/src/github.com/muvaf/typewriter/internal/header.txt"
-	file := wrapper.NewFile(targetPkgPath, targetPkgName, tmplPath,
-		wrapper.WithHeaderPath(headerPath),
-	)
-	vars := map[string]interface{}{}
+	file, err := newFile(c, targetPkgPath, templates.ProducersTemplate, headerPath, lint)
+	if err != nil {
+		return err
+	}
+	outPath := filepath.Join(targetPkgPath, "producers.go")
+	file.Imports.ReserveScope(cmd.TargetPackage(c, file.Imports, targetPkgPath, outPath).Scope())
 	f := cmd.NewFunctions(c, file.Imports, pkgPath,
-		cmd.WithNewFuncGeneratorFns(cmd.NewProducers))
+		cmd.WithNewFuncGeneratorFns(cmd.NewProducers),
+		cmd.WithValidation())
 	fns, err := f.Run()
 	if err != nil {
 		return err
 	}
-	for k, v := range fns {
-		vars[k] = v
-	}
-	final, err := file.Wrap(vars)
+	return errors.Wrap(file.Write(outPath, fns, 0644), "cannot write producers file")
+}
+
+// PrintAggregates generates the aggregate of the types in given full paths and
+// writes it to the aggregates.go file in the target package. All types in that
+// file are regenerated every time.
+func PrintAggregates(c *packages.Cache, typePaths []string, name, targetPkgPath string, o twtypes.FieldOrder, headerPath string, lint bool, opts ...twtypes.MergerOption) error {
+	file, err := newFile(c, targetPkgPath, templates.TypesTemplate, headerPath, lint)
 	if err != nil {
 		return err
 	}
-	if !disableLinter {
-		fb := bytes.NewBuffer(final)
-		command := exec.Command("goimports")
-		command.Stdin = fb
-		outb := &bytes.Buffer{}
-		command.Stdout = outb
-		if err := command.Run(); err != nil {
-			return errors.Wrap(err, "goimports failed")
+	outPath := filepath.Join(targetPkgPath, "aggregates.go")
+	pkg := cmd.TargetPackage(c, file.Imports, targetPkgPath, outPath)
+	if name == "" {
+		names := make([]string, len(typePaths))
+		for i, p := range typePaths {
+			names[i] = p[strings.LastIndex(p, ".")+1:]
 		}
-		final = outb.Bytes()
+		name = twtypes.DefaultAggregateName(names)
 	}
+	agg := cmd.NewAggregate(c, types.NewTypeName(0, pkg, name, nil), typePaths, opts...)
+	t := cmd.NewType(file.Imports, c, agg,
+		cmd.WithPrinterOptions(twtypes.WithComments(agg.Comments), twtypes.WithFieldOrder(o)))
+	out, err := t.Run()
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(file.Write(outPath, map[string]interface{}{"Types": out}, 0644), "cannot write aggregates file")
+}
+
+func newFile(c *packages.Cache, targetPkgPath, tmpl, headerPath string, lint bool) (*wrapper.File, error) {
 	if err := os.MkdirAll(targetPkgPath, os.ModePerm); err != nil {
-		return errors.Wrapf(err, "cannot create target package directory %s", targetPkgPath)
+		return nil, errors.Wrapf(err, "cannot create target package directory %s", targetPkgPath)
+	}
+	importPath, err := c.GetPackagePath(targetPkgPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get import path of target package")
+	}
+	modulePath, err := c.GetModulePath(targetPkgPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get module path of target package")
+	}
+	opts := []wrapper.FileOption{wrapper.WithHeaderPath(headerPath), wrapper.WithLocalPrefix(modulePath)}
+	if lint {
+		opts = append(opts, wrapper.LinterEnabled())
 	}
-	return errors.Wrap(ioutil.WriteFile(filepath.Join(targetPkgPath, "producers.go"), final, os.ModePerm), "cannot write to target file path")
+	return wrapper.NewFile(importPath, filepath.Base(targetPkgPath), tmpl, opts...), nil
 }
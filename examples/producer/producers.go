@@ -19,17 +19,40 @@ limitations under the License.
 package producer
 
 import (
-	app "github.com/muvaf/typewriter/examples/producer/app"
-	db "github.com/muvaf/typewriter/examples/producer/db"
+	"github.com/muvaf/typewriter/examples/producer/app"
+	"github.com/muvaf/typewriter/examples/producer/db"
 )
 
+// GenerateBelongingV1 returns a new db.BelongingV1 with the information from
+// given app.BelongingAll.
+func GenerateBelongingV1(a app.BelongingAll) db.BelongingV1 {
+	b := db.BelongingV1{}
+	if len(a.Automobiles) != 0 {
+		b.Automobiles = make([]string, len(a.Automobiles))
+		for v0 := range a.Automobiles {
+			b.Automobiles[v0] = a.Automobiles[v0]
+		}
+	}
+	return b
+}
+
+// GenerateBelongingV2 returns a new db.BelongingV2 with the information from
+// given app.BelongingAll.
+func GenerateBelongingV2(a app.BelongingAll) db.BelongingV2 {
+	b := db.BelongingV2{}
+	if len(a.Cars) != 0 {
+		b.Cars = make([]string, len(a.Cars))
+		for v0 := range a.Cars {
+			b.Cars[v0] = a.Cars[v0]
+		}
+	}
+	return b
+}
+
 // GenerateUserV1 returns a new db.UserV1 with the information from
 // given app.UserAll.
 func GenerateUserV1(a app.UserAll) db.UserV1 {
 	b := db.UserV1{}
-	b.Name = a.Name
-	b.Surname = a.Surname
-	b.Identifier = a.Identifier
 	if len(a.Belongings) != 0 {
 		b.Belongings = make([]db.BelongingV1, len(a.Belongings))
 		for v0 := range a.Belongings {
@@ -41,6 +64,9 @@ func GenerateUserV1(a app.UserAll) db.UserV1 {
 			}
 		}
 	}
+	b.Identifier = a.Identifier
+	b.Name = a.Name
+	b.Surname = a.Surname
 	return b
 }
 
@@ -48,9 +74,6 @@ func GenerateUserV1(a app.UserAll) db.UserV1 {
 // given app.UserAll.
 func GenerateUserV2(a app.UserAll) db.UserV2 {
 	b := db.UserV2{}
-	b.Name = a.Name
-	b.Id = a.Id
-	b.UserGroup = a.UserGroup
 	if len(a.Belongings) != 0 {
 		b.Belongings = make([]db.BelongingV2, len(a.Belongings))
 		for v0 := range a.Belongings {
@@ -62,5 +85,8 @@ func GenerateUserV2(a app.UserAll) db.UserV2 {
 			}
 		}
 	}
+	b.Id = a.Id
+	b.Name = a.Name
+	b.UserGroup = a.UserGroup
 	return b
 }
@@ -1,11 +1,14 @@
 module github.com/muvaf/typewriter
 
-go 1.16
+go 1.22.0
 
 require (
 	github.com/alecthomas/kong v0.2.16
 	github.com/google/addlicense v0.0.0-20210428195630-6d92264d7170
-	github.com/google/go-cmp v0.5.4
+	github.com/google/go-cmp v0.6.0
 	github.com/pkg/errors v0.9.1
-	golang.org/x/tools v0.1.0
+	golang.org/x/mod v0.23.0
+	golang.org/x/tools v0.30.0
 )
+
+require golang.org/x/sync v0.11.0 // indirect
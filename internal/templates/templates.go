@@ -0,0 +1,29 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package templates
+
+import (
+	_ "embed" // required for go:embed
+)
+
+// ProducersTemplate is the file template for the generated producer functions.
+//
+//go:embed producers.go.tmpl
+var ProducersTemplate string
+
+// TypesTemplate is the file template for the generated types.
+//
+//go:embed types.go.tmpl
+var TypesTemplate string
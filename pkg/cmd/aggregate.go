@@ -0,0 +1,69 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"go/types"
+
+	"github.com/pkg/errors"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+	twtypes "github.com/muvaf/typewriter/pkg/types"
+)
+
+func NewAggregate(cache *packages.Cache, name *types.TypeName, inputPaths []string, opts ...twtypes.MergerOption) *Aggregate {
+	return &Aggregate{
+		cache:      cache,
+		name:       name,
+		inputPaths: inputPaths,
+		opts:       opts,
+		Comments:   twtypes.Comments{},
+	}
+}
+
+// Aggregate generates the aggregate type of the types in given paths together
+// with the aggregates of their nested types. The generated types are marked
+// with the types they are aggregate of so that their producers can be
+// generated right away.
+type Aggregate struct {
+	cache      *packages.Cache
+	name       *types.TypeName
+	inputPaths []string
+	opts       []twtypes.MergerOption
+
+	// Comments holds the markers of all generated types once Generate is
+	// called. It should be given to the type printer.
+	Comments twtypes.Comments
+}
+
+func (a *Aggregate) Generate() (*types.Named, *packages.CommentMarkers, error) {
+	inputs := make([]*types.Named, len(a.inputPaths))
+	for i, p := range a.inputPaths {
+		t, err := a.cache.GetTypeWithFullPath(p)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "cannot get type %s", p)
+		}
+		inputs[i] = t
+	}
+	m := twtypes.NewMerger(a.name, inputs, a.opts...)
+	n, cm, err := m.Generate()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "cannot merge types into %s", a.name.Name())
+	}
+	for k, v := range m.Comments {
+		a.Comments[k] = v
+	}
+	return n, &cm, nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2022 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"go/format"
+	"go/types"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+	"github.com/muvaf/typewriter/pkg/test"
+	twtypes "github.com/muvaf/typewriter/pkg/types"
+)
+
+const aggregateSource = `
+package db
+
+type UserV1 struct {
+	Name       string
+	Identifier int
+	Belongings []BelongingV1
+	Address    *AddressV1
+}
+
+type BelongingV1 struct {
+	Automobiles []string
+}
+
+type AddressV1 struct {
+	City string
+}
+
+type UserV2 struct {
+	Name       string
+	Belongings []BelongingV2
+	Address    *AddressV2
+	UserGroup  string
+}
+
+type BelongingV2 struct {
+	Cars []string
+}
+
+type AddressV2 struct {
+	City    string
+	Country string
+}
+`
+
+func TestAggregate(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		name   string
+		inputs []string
+		want   string
+		err    bool
+	}{
+		"NestedAggregates": {
+			reason: "Nested types should be aggregated recursively, the fields should refer to the nested aggregates and all aggregates should be marked with their input types.",
+			name:   "UserAll",
+			inputs: []string{"simple.go.UserV1", "simple.go.UserV2"},
+			want: `package app
+
+// +typewriter:types:aggregated=simple.go.AddressV1
+// +typewriter:types:aggregated=simple.go.AddressV2
+type AddressAll struct {
+	City string
+
+	Country string
+}
+
+// +typewriter:types:aggregated=simple.go.BelongingV1
+// +typewriter:types:aggregated=simple.go.BelongingV2
+type BelongingAll struct {
+	Automobiles []string
+
+	Cars []string
+}
+
+// +typewriter:types:aggregated=simple.go.UserV1
+// +typewriter:types:aggregated=simple.go.UserV2
+type UserAll struct {
+	Name string
+
+	Identifier int
+
+	Belongings []BelongingAll
+
+	Address *AddressAll
+
+	UserGroup string
+}
+`,
+		},
+		"MissingType": {
+			reason: "An error should be returned if an input type cannot be found.",
+			name:   "UserAll",
+			inputs: []string{"simple.go.UserV1", "simple.go.UserV3"},
+			err:    true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cache := packages.NewCache(test.ParsePackage(aggregateSource))
+			target := types.NewPackage("example.com/app", "app")
+			im := packages.NewImports(target.Path(), target.Name())
+			agg := NewAggregate(cache, types.NewTypeName(0, target, tc.name, nil), tc.inputs)
+			out, err := NewType(im, cache, agg, WithPrinterOptions(twtypes.WithComments(agg.Comments))).Run()
+			if (err != nil) != tc.err {
+				t.Fatalf("\n%s\nRun(): unexpected error: %v", tc.reason, err)
+			}
+			if tc.err {
+				return
+			}
+			got, err := format.Source([]byte("package app\n" + out))
+			if err != nil {
+				t.Fatalf("\n%s\nRun(): output is not valid Go: %s\n%s", tc.reason, err, out)
+			}
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("\n%s\nRun(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+	twtypes "github.com/muvaf/typewriter/pkg/types"
+)
+
+// NewMarkedTypeGeneratorFn returns the generator of the type that should be
+// generated for the given marked type, or nil if there is none.
+type NewMarkedTypeGeneratorFn func(t *types.Named, cm *packages.CommentMarkers) (TypeGenerator, error)
+
+// WithTypeGenerators adds generators whose types will be printed.
+func WithTypeGenerators(gens ...TypeGenerator) TypeBatchOption {
+	return func(b *TypeBatch) {
+		b.Generators = append(b.Generators, gens...)
+	}
+}
+
+// WithMarkedTypes makes the batch create a generator for every type in the
+// source package that has markers using the given function.
+func WithMarkedTypes(sourcePkgPath string, fn NewMarkedTypeGeneratorFn) TypeBatchOption {
+	return func(b *TypeBatch) {
+		b.SourcePackagePath = sourcePkgPath
+		b.NewMarkedGeneratorFn = fn
+	}
+}
+
+// WithFilePerRoot makes the batch print the types of every generated type into
+// a separate output with its own imports.
+func WithFilePerRoot() TypeBatchOption {
+	return func(b *TypeBatch) {
+		b.FilePerRoot = true
+	}
+}
+
+// WithBatchFlattenerOptions adds options to the flattener that copies the
+// types of all generators into the target package.
+func WithBatchFlattenerOptions(fo ...twtypes.FlattenerOption) TypeBatchOption {
+	return func(b *TypeBatch) {
+		b.FlattenerOptions = append(b.FlattenerOptions, fo...)
+	}
+}
+
+// WithBatchPrinterOptions adds options to the printers of the batch.
+func WithBatchPrinterOptions(po ...twtypes.PrinterOption) TypeBatchOption {
+	return func(b *TypeBatch) {
+		b.PrinterOptions = append(b.PrinterOptions, po...)
+	}
+}
+
+type TypeBatchOption func(*TypeBatch)
+
+func NewTypeBatch(im *packages.Imports, cache *packages.Cache, target *types.Package, opts ...TypeBatchOption) *TypeBatch {
+	b := &TypeBatch{
+		Imports: im,
+		Cache:   cache,
+		Target:  target,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// TypeBatch generates and prints many types into the same target package. The
+// target package is given once and the nested types shared by the generated
+// types are copied and printed only once. The scope of the target package is
+// not changed.
+type TypeBatch struct {
+	Imports *packages.Imports
+	Cache   *packages.Cache
+	Target  *types.Package
+
+	Generators           []TypeGenerator
+	SourcePackagePath    string
+	NewMarkedGeneratorFn NewMarkedTypeGeneratorFn
+	FilePerRoot          bool
+	FlattenerOptions     []twtypes.FlattenerOption
+	PrinterOptions       []twtypes.PrinterOption
+}
+
+// TypeBatchOutput is the printed types that should be written into the same
+// file.
+type TypeBatchOutput struct {
+	// Root is the name of the generated type that the types are printed for.
+	// It's empty if the types of all generated types are printed together.
+	Root    string
+	Imports *packages.Imports
+	Types   string
+}
+
+// Run returns a single output with all types or an output for every generated
+// type with the types that are not printed for the previous ones.
+func (b *TypeBatch) Run() ([]TypeBatchOutput, error) {
+	gens, err := b.generators()
+	if err != nil {
+		return nil, err
+	}
+	b.Imports.ReserveScope(b.Target.Scope())
+	fl := twtypes.NewFlattener(b.Imports, append([]twtypes.FlattenerOption{twtypes.WithLocalPkg(b.Target)}, b.FlattenerOptions...)...)
+	// Printers of all generated types share the types printed so far so
+	// that the shared nested types are printed once.
+	overlay := types.NewScope(nil, token.NoPos, token.NoPos, "overlay")
+	var result []TypeBatchOutput
+	single := TypeBatchOutput{Imports: b.Imports}
+	for _, gen := range gens {
+		generated, _, err := gen.Generate()
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot generate type")
+		}
+		im := b.Imports
+		if b.FilePerRoot {
+			im = packages.NewImports(b.Imports.PackagePath, b.Imports.PackageName)
+			im.ReserveScope(b.Target.Scope())
+		}
+		// Flattener uses the imports for the methods it copies.
+		fl.Imports = im
+		flattened, err := fl.Flatten(generated)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot flatten generated type %s", generated.Obj().Name())
+		}
+		po := append([]twtypes.PrinterOption{twtypes.WithOverlay(overlay)}, b.PrinterOptions...)
+		out, err := newPrinter(im, b.Target.Scope(), fl, po...).Print(flattened)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot print generated type %s", generated.Obj().Name())
+		}
+		if !b.FilePerRoot {
+			single.Types += out
+			continue
+		}
+		result = append(result, TypeBatchOutput{Root: generated.Obj().Name(), Imports: im, Types: out})
+	}
+	if !b.FilePerRoot {
+		result = append(result, single)
+	}
+	return result, nil
+}
+
+// generators returns the given generators followed by the ones of the marked
+// types in the order of their names.
+func (b *TypeBatch) generators() ([]TypeGenerator, error) {
+	result := append([]TypeGenerator{}, b.Generators...)
+	if b.NewMarkedGeneratorFn == nil {
+		return result, nil
+	}
+	sourcePkg, err := b.Cache.GetPackage(b.SourcePackagePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get source package")
+	}
+	recipe, err := packages.LoadCommentMarkers(sourcePkg)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot scan comment markers")
+	}
+	sourceTypes := make([]*types.Named, 0, len(recipe))
+	for t := range recipe {
+		sourceTypes = append(sourceTypes, t)
+	}
+	sort.Slice(sourceTypes, func(i, j int) bool {
+		return sourceTypes[i].Obj().Name() < sourceTypes[j].Obj().Name()
+	})
+	for _, t := range sourceTypes {
+		gen, err := b.NewMarkedGeneratorFn(t, recipe[t])
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot create generator for type %s", t.Obj().Name())
+		}
+		if gen != nil {
+			result = append(result, gen)
+		}
+	}
+	return result, nil
+}
+
+// TargetPackage returns the package the types will be generated in. Its scope
+// contains the existing types of the package except the ones in the files that
+// will be overwritten so that they're not skipped as existing types. It's
+// meant to be loaded once and shared by all types generated in the package.
+func TargetPackage(c *packages.Cache, im *packages.Imports, targetPkgPath string, outPaths ...string) *types.Package {
+	result := types.NewPackage(im.PackagePath, im.PackageName)
+	// The package may not exist yet or fail to load because of a stale output
+	// file, in which case all types are generated.
+	existing, err := c.GetPackage(targetPkgPath)
+	if err != nil {
+		return result
+	}
+	out := map[string]struct{}{}
+	for _, p := range outPaths {
+		abs, _ := filepath.Abs(p)
+		out[abs] = struct{}{}
+	}
+	for _, n := range existing.Types.Scope().Names() {
+		o := existing.Types.Scope().Lookup(n)
+		if _, ok := out[existing.Fset.Position(o.Pos()).Filename]; ok {
+			continue
+		}
+		result.Scope().Insert(o)
+	}
+	return result
+}
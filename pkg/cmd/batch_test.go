@@ -0,0 +1,285 @@
+// Copyright 2022 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"go/format"
+	"go/types"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+	"github.com/muvaf/typewriter/pkg/test"
+	twtypes "github.com/muvaf/typewriter/pkg/types"
+)
+
+const batchSource = `
+package sdk
+
+import (
+	"strings"
+	"time"
+)
+
+type Status struct {
+	Code      int
+	UpdatedAt time.Time
+}
+
+// +typewriter:types:generate=true
+type Instance struct {
+	Status Status
+}
+
+// +typewriter:types:generate=true
+type Volume struct {
+	Status *Status
+	Name   string
+}
+
+func (v Volume) Title() string {
+	return strings.ToUpper(v.Name)
+}
+
+// +typewriter:types:generate=false
+type Snapshot struct {
+	Status Status
+}
+`
+
+type staticGenerator struct {
+	t *types.Named
+}
+
+func (g staticGenerator) Generate() (*types.Named, *packages.CommentMarkers, error) {
+	return g.t, nil, nil
+}
+
+// markedGenerators returns generators for the types whose generate marker is
+// true.
+func markedGenerators(t *types.Named, cm *packages.CommentMarkers) (TypeGenerator, error) {
+	v := cm.Values(packages.SectionTypes, "generate")
+	if len(v) == 0 || v[0] != "true" {
+		return nil, nil
+	}
+	return staticGenerator{t: t}, nil
+}
+
+type batchOutput struct {
+	Root    string
+	Imports map[string]string
+	Types   string
+}
+
+func TestTypeBatchRun(t *testing.T) {
+	errBoom := errors.New("boom")
+	cases := map[string]struct {
+		reason string
+		// roots are given as generators before the marked types.
+		roots  []string
+		marked NewMarkedTypeGeneratorFn
+		opts   []TypeBatchOption
+		want   []batchOutput
+		err    error
+	}{
+		"SingleOutput": {
+			reason: "Types of all generated types should be printed into a single output with the shared nested types printed once.",
+			roots:  []string{"Instance", "Volume"},
+			want: []batchOutput{
+				{
+					Imports: map[string]string{"strings": "strings", "time": "time"},
+					Types: `package target
+
+type Instance struct {
+	Status Status
+}
+
+type Status struct {
+	Code int
+
+	UpdatedAt time.Time
+}
+
+type Volume struct {
+	Status *Status
+
+	Name string
+}
+
+func (v Volume) Title() string {
+	return strings.ToUpper(v.Name)
+}
+`,
+				},
+			},
+		},
+		"FilePerRoot": {
+			reason: "Every generated type should have its own output and imports with the shared nested types printed only for the first one.",
+			roots:  []string{"Instance", "Volume"},
+			opts:   []TypeBatchOption{WithFilePerRoot()},
+			want: []batchOutput{
+				{
+					Root:    "Instance",
+					Imports: map[string]string{"time": "time"},
+					Types: `package target
+
+type Instance struct {
+	Status Status
+}
+
+type Status struct {
+	Code int
+
+	UpdatedAt time.Time
+}
+`,
+				},
+				{
+					Root:    "Volume",
+					Imports: map[string]string{"strings": "strings"},
+					Types: `package target
+
+type Volume struct {
+	Status *Status
+
+	Name string
+}
+
+func (v Volume) Title() string {
+	return strings.ToUpper(v.Name)
+}
+`,
+				},
+			},
+		},
+		"MarkedTypes": {
+			reason: "Generators of the marked types should follow the given ones in the order of the type names.",
+			roots:  []string{"Volume"},
+			marked: markedGenerators,
+			opts:   []TypeBatchOption{WithFilePerRoot()},
+			want: []batchOutput{
+				{
+					Root:    "Volume",
+					Imports: map[string]string{"strings": "strings", "time": "time"},
+					Types: `package target
+
+type Status struct {
+	Code int
+
+	UpdatedAt time.Time
+}
+
+type Volume struct {
+	Status *Status
+
+	Name string
+}
+
+func (v Volume) Title() string {
+	return strings.ToUpper(v.Name)
+}
+`,
+				},
+				{
+					Root:    "Instance",
+					Imports: map[string]string{},
+					Types: `package target
+
+type Instance struct {
+	Status Status
+}
+`,
+				},
+				{
+					// Volume is marked, too, but generating the same type
+					// again doesn't print anything new.
+					Root:    "Volume",
+					Imports: map[string]string{},
+					Types: `package target
+`,
+				},
+			},
+		},
+		"AddedOptions": {
+			reason: "Flattener options should be added to the ones given before instead of replacing them.",
+			roots:  []string{"Instance"},
+			opts:   []TypeBatchOption{WithBatchFlattenerOptions(twtypes.WithTypeNameSuffix("Parameters"))},
+			want: []batchOutput{
+				{
+					Imports: map[string]string{"time": "time"},
+					Types: `package target
+
+type InstanceParameters struct {
+	Status StatusParameters
+}
+
+type StatusParameters struct {
+	Code int
+
+	UpdatedAt time.Time
+}
+`,
+				},
+			},
+		},
+		"MarkedTypeError": {
+			reason: "Errors of creating the generators of the marked types should be returned.",
+			marked: func(_ *types.Named, _ *packages.CommentMarkers) (TypeGenerator, error) {
+				return nil, errBoom
+			},
+			err: errors.Wrap(errBoom, "cannot create generator for type Instance"),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			src := test.ParsePackage(batchSource)
+			cache := packages.NewCache(src)
+			target := types.NewPackage("example.com/target", "target")
+			im := packages.NewImports(target.Path(), target.Name())
+			opts := append([]TypeBatchOption{
+				WithBatchFlattenerOptions(
+					twtypes.WithRemotePkgPath(src.PkgPath),
+					twtypes.WithFlattenMethods(cache),
+				),
+			}, tc.opts...)
+			for _, r := range tc.roots {
+				opts = append(opts, WithTypeGenerators(staticGenerator{t: src.Types.Scope().Lookup(r).Type().(*types.Named)}))
+			}
+			if tc.marked != nil {
+				opts = append(opts, WithMarkedTypes(src.PkgPath, tc.marked))
+			}
+			out, err := NewTypeBatch(im, cache, target, opts...).Run()
+			if diff := cmp.Diff(tc.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("Run(): %s\n-want error, +got error:\n%s", tc.reason, diff)
+			}
+			if tc.err != nil {
+				return
+			}
+			got := make([]batchOutput, len(out))
+			for i, o := range out {
+				f, err := format.Source([]byte("package target\n" + o.Types))
+				if err != nil {
+					t.Fatalf("Run(): output is not valid Go: %s\n%s", err, o.Types)
+				}
+				got[i] = batchOutput{Root: o.Root, Imports: o.Imports.Imports, Types: string(f)}
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Run(): %s\n-want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
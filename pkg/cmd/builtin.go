@@ -26,30 +26,35 @@ import (
 
 func NewProducers(cache *packages.Cache, im *packages.Imports) FuncGenerator {
 	return &Producers{
-		cache:   cache,
-		imports: im,
+		cache:    cache,
+		comments: packages.NewCommentCache(cache),
+		imports:  im,
 	}
 }
 
-// Producers generates a function for every merged type of the given type that will
-// let you produce those remote types from the local one.
+// Producers generates a function for every aggregated type of the given type
+// that will let you produce those remote types from the local one.
 type Producers struct {
-	cache   *packages.Cache
-	imports *packages.Imports
+	cache    *packages.Cache
+	comments *packages.CommentCache
+	imports  *packages.Imports
 }
 
 func (p *Producers) Generate(source *types.Named, cm *packages.CommentMarkers) (map[string]interface{}, error) {
-	merged := cm.SectionContents[packages.SectionMerged]
-	if len(merged) == 0 {
+	aggregated := cm.Values(packages.SectionTypes, packages.TypesAggregated)
+	if len(aggregated) == 0 {
 		return nil, nil
 	}
 	result := ""
-	for _, target := range merged {
+	for _, target := range aggregated {
 		targetType, err := p.cache.GetTypeWithFullPath(target)
 		if err != nil {
 			return nil, errors.Wrap(err, "cannot get target type")
 		}
-		fn := traverser.NewPrinter(p.imports, traverser.NewGeneric(p.imports))
+		fn := traverser.NewPrinter(p.imports,
+			traverser.NewGeneric(p.imports, traverser.WithCommentCache(p.comments)),
+			traverser.WithDefaultValues(p.comments),
+		)
 		funcName := fmt.Sprintf("Generate%s", targetType.Obj().Name())
 		generated, err := fn.Print(funcName, source, targetType, nil)
 		if err != nil {
@@ -15,9 +15,14 @@
 package cmd
 
 import (
+	"go/types"
+	"sort"
+	"strings"
+
 	"github.com/pkg/errors"
 
 	"github.com/muvaf/typewriter/pkg/packages"
+	"github.com/muvaf/typewriter/pkg/traverser"
 )
 
 func WithNewFuncGeneratorFns(fn ...NewFuncGeneratorFn) FunctionsOption {
@@ -26,6 +31,16 @@ func WithNewFuncGeneratorFns(fn ...NewFuncGeneratorFn) FunctionsOption {
 	}
 }
 
+// WithValidation makes Run type-check the generated code together with the
+// existing files of the target package, which catches the mistakes in
+// expressions given via markers before the code is written. All generated
+// functions are checked at once.
+func WithValidation() FunctionsOption {
+	return func(f *Functions) {
+		f.Validate = true
+	}
+}
+
 type FunctionsOption func(*Functions)
 
 func NewFunctions(c *packages.Cache, i *packages.Imports, sourcePkgPath string, opts ...FunctionsOption) *Functions {
@@ -47,6 +62,7 @@ type Functions struct {
 
 	SourcePackagePath string
 	NewGeneratorFns   []NewFuncGeneratorFn
+	Validate          bool
 }
 
 func (f *Functions) Run() (map[string]interface{}, error) {
@@ -62,15 +78,53 @@ func (f *Functions) Run() (map[string]interface{}, error) {
 	for _, fn := range f.NewGeneratorFns {
 		gens = append(gens, fn(f.cache, f.imports))
 	}
+	// Types are processed in the order of their names for stable output.
+	sourceTypes := make([]*types.Named, 0, len(recipe))
+	for t := range recipe {
+		sourceTypes = append(sourceTypes, t)
+	}
+	sort.Slice(sourceTypes, func(i, j int) bool {
+		return sourceTypes[i].Obj().Name() < sourceTypes[j].Obj().Name()
+	})
 	input := map[string]interface{}{}
-	for sourceType, commentMarker := range recipe {
-		generated, err := gens.Generate(sourceType, commentMarker)
+	for _, sourceType := range sourceTypes {
+		generated, err := gens.Generate(sourceType, recipe[sourceType])
 		if err != nil {
 			return nil, errors.Wrapf(err, "cannot run generators for type %s", sourceType.Obj().Name())
 		}
 		for k, v := range generated {
+			// Output of the same generator for different types are
+			// concatenated so that all of them end up in the file.
+			if prev, ok := input[k].(string); ok {
+				if s, ok := v.(string); ok {
+					input[k] = prev + s
+					continue
+				}
+			}
 			input[k] = v
 		}
 	}
+	if f.Validate {
+		if err := f.validate(input); err != nil {
+			return nil, errors.Wrap(err, "cannot validate generated functions")
+		}
+	}
 	return input, nil
 }
+
+// validate type-checks the Go code in the outputs of the generators in the
+// order of their keys.
+func (f *Functions) validate(input map[string]interface{}) error {
+	keys := make([]string, 0, len(input))
+	for k := range input {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	src := &strings.Builder{}
+	for _, k := range keys {
+		if s, ok := input[k].(string); ok {
+			src.WriteString(s)
+		}
+	}
+	return traverser.Validate(f.cache, f.imports, src.String())
+}
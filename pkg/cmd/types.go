@@ -15,24 +15,26 @@
 package cmd
 
 import (
+	"go/types"
+
 	"github.com/pkg/errors"
 
 	"github.com/muvaf/typewriter/pkg/packages"
-	"github.com/muvaf/typewriter/pkg/types"
+	twtypes "github.com/muvaf/typewriter/pkg/types"
 )
 
-// TODO(muvaf): Type can generate & print single type right now. However, that
-// causes *types.Scope to be exposed as high level argument because we need to
-// check for duplicates in the local package. If we accept only package path, then
-// the package will be loaded (expensive) every time a type is generated. Consider
-// the ability to allow multiple types to be printed.
-
-func WithFlattenerOption(fo types.FlattenerOption) TypeOption {
+func WithFlattenerOption(fo twtypes.FlattenerOption) TypeOption {
 	return func(t *Type) {
 		t.FlattenerOption = fo
 	}
 }
 
+func WithPrinterOptions(po ...twtypes.PrinterOption) TypeOption {
+	return func(t *Type) {
+		t.PrinterOptions = po
+	}
+}
+
 type TypeOption func(*Type)
 
 func NewType(im *packages.Imports, cache *packages.Cache, gen TypeGenerator, opts ...TypeOption) *Type {
@@ -52,7 +54,8 @@ type Type struct {
 	Imports         *packages.Imports
 	Cache           *packages.Cache
 	Generator       TypeGenerator
-	FlattenerOption types.FlattenerOption
+	FlattenerOption twtypes.FlattenerOption
+	PrinterOptions  []twtypes.PrinterOption
 }
 
 func (t *Type) Run() (string, error) {
@@ -60,10 +63,51 @@ func (t *Type) Run() (string, error) {
 	if err != nil {
 		return "", errors.Wrap(err, "cannot generate type")
 	}
-	flattened := types.NewFlattener(t.Imports, t.FlattenerOption,
-		types.WithLocalPkg(generated.Obj().Pkg()),
-	).Flatten(generated)
-	printer := types.NewPrinter(t.Imports, generated.Obj().Pkg().Scope())
+	t.Imports.ReserveScope(generated.Obj().Pkg().Scope())
+	fo := []twtypes.FlattenerOption{twtypes.WithLocalPkg(generated.Obj().Pkg())}
+	if t.FlattenerOption != nil {
+		fo = append(fo, t.FlattenerOption)
+	}
+	fl := twtypes.NewFlattener(t.Imports, fo...)
+	flattened, err := fl.Flatten(generated)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot flatten generated type")
+	}
+	printer := newPrinter(t.Imports, generated.Obj().Pkg().Scope(), fl, t.PrinterOptions...)
 	structStr, err := printer.Print(flattened)
 	return structStr, errors.Wrapf(err, "cannot print generated type %s", structStr)
 }
+
+// newPrinter returns a printer that has what's collected by the flattener in
+// addition to what's given in the options.
+func newPrinter(im *packages.Imports, scope *types.Scope, fl *twtypes.Flattener, opts ...twtypes.PrinterOption) *twtypes.Printer {
+	printer := twtypes.NewPrinter(im, scope, opts...)
+	// Comments given to the printer, like the ones of the generator, take
+	// precedence over the ones transferred during flattening.
+	for k, v := range fl.Comments {
+		if _, ok := printer.Comments[k]; !ok {
+			printer.Comments[k] = v
+		}
+	}
+	for k, v := range fl.Constants {
+		if _, ok := printer.Constants[k]; !ok {
+			printer.Constants[k] = v
+		}
+	}
+	for k, v := range fl.Methods {
+		if _, ok := printer.Methods[k]; !ok {
+			printer.Methods[k] = v
+		}
+	}
+	for k, v := range fl.Sources {
+		if _, ok := printer.Sources[k]; !ok {
+			printer.Sources[k] = v
+		}
+	}
+	for k, v := range fl.Declared {
+		if _, ok := printer.Declared[k]; !ok {
+			printer.Declared[k] = v
+		}
+	}
+	return printer
+}
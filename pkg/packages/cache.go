@@ -16,36 +16,88 @@ package packages
 
 import (
 	"fmt"
+	"go/build"
 	"go/types"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/pkg/errors"
+	"golang.org/x/mod/modfile"
 	"golang.org/x/tools/go/packages"
 )
 
 const (
 	LoadMode = packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps | packages.NeedTypes | packages.NeedSyntax
+
+	// LoadModeWithTypesInfo additionally loads the type information of the
+	// syntax, which is needed to rewrite the source code of the packages, like
+	// copying methods. It's considerably slower than LoadMode.
+	LoadModeWithTypesInfo = LoadMode | packages.NeedTypesInfo
 )
 
 func NewCache(init ...*packages.Package) *Cache {
+	return NewCacheWithMode(LoadMode, init...)
+}
+
+// NewCacheWithMode returns a Cache that loads the packages with given mode.
+func NewCacheWithMode(mode packages.LoadMode, init ...*packages.Package) *Cache {
 	s := map[string]*packages.Package{}
 	for _, p := range init {
 		s[p.PkgPath] = p
 	}
 	return &Cache{
 		store: s,
+		mode:  mode,
 	}
 }
 
 type Cache struct {
 	store map[string]*packages.Package
+	mode  packages.LoadMode
 }
 
 // GetTypeWithFullPath returns the type information of the type in given path. The expected
-// format is "<package path>.<type name>".
+// format is "<package path>.<type name>". Generic types can be instantiated by
+// giving the type arguments in the same format, i.e. "<package path>.<type name>[int, <package path>.<type name>]".
 func (pc *Cache) GetTypeWithFullPath(fullPath string) (*types.Named, error) {
-	path, name := fullPath[:strings.LastIndex(fullPath, ".")], fullPath[strings.LastIndex(fullPath, ".")+1:]
-	return pc.GetType(path, name)
+	if base, args, ok := splitTypeArgs(fullPath); ok {
+		return pc.getInstance(base, args)
+	}
+	i := strings.LastIndex(fullPath, ".")
+	if i <= 0 || i == len(fullPath)-1 {
+		return nil, errors.Errorf("type %s is not in <package path>.<type name> format", fullPath)
+	}
+	return pc.GetType(fullPath[:i], fullPath[i+1:])
+}
+
+func (pc *Cache) getInstance(base string, args []string) (*types.Named, error) {
+	origin, err := pc.GetTypeWithFullPath(base)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get generic type %s", base)
+	}
+	targs := make([]types.Type, len(args))
+	for i, a := range args {
+		if o := types.Universe.Lookup(a); o != nil {
+			if _, ok := o.(*types.TypeName); ok {
+				targs[i] = o.Type()
+				continue
+			}
+		}
+		if !strings.Contains(a, ".") {
+			return nil, errors.Errorf("type argument %s is neither a predeclared type nor in <package path>.<type name> format", a)
+		}
+		t, err := pc.GetTypeWithFullPath(a)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get type argument %s", a)
+		}
+		targs[i] = t
+	}
+	inst, err := types.Instantiate(nil, origin, targs, true)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot instantiate %s", base)
+	}
+	return inst.(*types.Named), nil
 }
 
 // GetType returns the type information of the type in given path. The expected
@@ -65,34 +117,126 @@ func (pc *Cache) GetType(packagePath, name string) (*types.Named, error) {
 	return nil, errors.Errorf("type %s is not a named struct", name)
 }
 
-// GetPackage accepts absolute local path or Go module path and returns a single
-// package. It caches by Go module path in both cases.
-func (pc *Cache) GetPackage(absolutePath string) (*packages.Package, error) {
-	// Path could be local path or module path but we cache with module path.
-	// Since local path has the module path as suffix albeit we don't really know
-	// the seperator, we can iterate and find any package whose path has given path
-	// as suffix.
-	for p, pkg := range pc.store {
-		if strings.HasSuffix(absolutePath, p) {
-			return pkg, nil
+// GetPackage accepts local path or Go package path and returns a single
+// package. It caches by Go package path in both cases.
+func (pc *Cache) GetPackage(path string) (*packages.Package, error) {
+	// Local paths are matched with the directory of the package since they are
+	// not necessarily suffixed with the package path, i.e. when the module is
+	// not in GOPATH.
+	dir := ""
+	if build.IsLocalImport(path) || filepath.IsAbs(path) {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get absolute path of %s", path)
+		}
+		dir = abs
+	}
+	if pkg, ok := pc.store[path]; ok {
+		return pkg, nil
+	}
+	if dir != "" {
+		for _, pkg := range pc.store {
+			if packageDir(pkg) == dir {
+				return pkg, nil
+			}
 		}
 	}
-	pkgs, err := packages.Load(&packages.Config{Mode: LoadMode}, absolutePath)
+	pkgs, err := packages.Load(&packages.Config{Mode: pc.mode}, path)
 	if err != nil {
-		return nil, errors.Wrapf(err, "cannot load packages in %s", absolutePath)
+		return nil, errors.Wrapf(err, "cannot load packages in %s", path)
 	}
 	for _, pkg := range pkgs {
-		if strings.HasSuffix(absolutePath, pkg.PkgPath) {
-			if len(pkg.Errors) != 0 {
-				errStr := ""
-				for _, e := range pkg.Errors {
-					errStr += fmt.Sprintf("%s ", e.Error())
-				}
-				return nil, errors.Errorf("cannot load package with error: %s", errStr)
+		if pkg.PkgPath != path && (dir == "" || packageDir(pkg) != dir) {
+			continue
+		}
+		if len(pkg.Errors) != 0 {
+			errStr := ""
+			for _, e := range pkg.Errors {
+				errStr += fmt.Sprintf("%s ", e.Error())
 			}
-			pc.store[pkg.PkgPath] = pkg
-			return pkg, nil
+			return nil, errors.Errorf("cannot load package with error: %s", errStr)
 		}
+		pc.store[pkg.PkgPath] = pkg
+		return pkg, nil
+	}
+	return nil, errors.Errorf("cannot find package in %s", path)
+}
+
+// packageDir returns the directory of the given package, which is empty if
+// none of its files is known.
+func packageDir(pkg *packages.Package) string {
+	if len(pkg.GoFiles) == 0 {
+		return ""
 	}
-	return nil, errors.Errorf("cannot find package in %s", absolutePath)
+	return filepath.Dir(pkg.GoFiles[0])
+}
+
+// GetPackagePath returns the Go package path of the package in given local
+// path. Unlike GetPackage, the package doesn't need to exist or compile, which
+// is the case for the packages that code will be generated into.
+func (pc *Cache) GetPackagePath(localPath string) (string, error) {
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedName}, localPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot load packages in %s", localPath)
+	}
+	if len(pkgs) != 1 {
+		return "", errors.Errorf("expected a single package in %s, found %d", localPath, len(pkgs))
+	}
+	return pkgs[0].PkgPath, nil
+}
+
+// GetModulePath returns the path of the Go module the package in given local
+// path belongs to. The package doesn't need to exist, in which case the module
+// is found using the closest go.mod file.
+func (pc *Cache) GetModulePath(localPath string) (string, error) {
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedName | packages.NeedModule}, localPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot load packages in %s", localPath)
+	}
+	if len(pkgs) == 1 && pkgs[0].Module != nil {
+		return pkgs[0].Module.Path, nil
+	}
+	// The module is not reported for the directories without Go files, like
+	// the ones that code is generated into for the first time.
+	dir, err := filepath.Abs(localPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot get absolute path of %s", localPath)
+	}
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			if p := modfile.ModulePath(data); p != "" {
+				return p, nil
+			}
+			return "", errors.Errorf("cannot find module path in %s", filepath.Join(dir, "go.mod"))
+		}
+		if !os.IsNotExist(err) {
+			return "", errors.Wrapf(err, "cannot read %s", filepath.Join(dir, "go.mod"))
+		}
+		if filepath.Dir(dir) == dir {
+			return "", errors.Errorf("cannot find the module of the package in %s", localPath)
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// Importer returns a types.Importer that serves the packages from the cache and
+// loads the ones that don't exist yet.
+func (pc *Cache) Importer() types.Importer {
+	return importerFunc(func(path string) (*types.Package, error) {
+		if path == "unsafe" {
+			return types.Unsafe, nil
+		}
+		p, err := pc.GetPackage(path)
+		if err != nil {
+			return nil, err
+		}
+		return p.Types, nil
+	})
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
 }
@@ -0,0 +1,220 @@
+// Copyright 2022 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packages
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/muvaf/typewriter/pkg/test"
+)
+
+func TestCacheGetPackage(t *testing.T) {
+	abs, err := filepath.Abs(".")
+	if err != nil {
+		t.Fatalf("cannot get absolute path: %s", err)
+	}
+	type args struct {
+		init []*packages.Package
+		path string
+	}
+	type want struct {
+		pkgPath string
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"PackagePath": {
+			reason: "Packages should be returned by their exact package path.",
+			args: args{
+				path: "github.com/pkg/errors",
+			},
+			want: want{
+				pkgPath: "github.com/pkg/errors",
+			},
+		},
+		"CachedSuffix": {
+			reason: "A cached package whose path is a suffix of the given path should not be returned.",
+			args: args{
+				init: []*packages.Package{{PkgPath: "errors", Name: "errors"}},
+				path: "github.com/pkg/errors",
+			},
+			want: want{
+				pkgPath: "github.com/pkg/errors",
+			},
+		},
+		"LocalPath": {
+			reason: "Packages should be returned by their local directory.",
+			args: args{
+				path: abs,
+			},
+			want: want{
+				pkgPath: "github.com/muvaf/typewriter/pkg/packages",
+			},
+		},
+		"RelativeLocalPath": {
+			reason: "Relative local paths should be resolved to the directory of the package.",
+			args: args{
+				init: []*packages.Package{{PkgPath: "packages", Name: "packages"}},
+				path: "./",
+			},
+			want: want{
+				pkgPath: "github.com/muvaf/typewriter/pkg/packages",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := NewCache(tc.args.init...)
+			p, err := c.GetPackage(tc.args.path)
+			if err != nil {
+				t.Fatalf("\n%s\nGetPackage(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.pkgPath, p.PkgPath); diff != "" {
+				t.Errorf("\n%s\nGetPackage(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			again, err := c.GetPackage(tc.want.pkgPath)
+			if err != nil || again != p {
+				t.Errorf("\n%s\nGetPackage(...): package is not cached with its package path", tc.reason)
+			}
+		})
+	}
+}
+
+func TestCacheGetModulePath(t *testing.T) {
+	// A directory without Go files in this module, like the target of the
+	// generated code that doesn't exist yet.
+	empty, err := os.MkdirTemp(".", "empty")
+	if err != nil {
+		t.Fatalf("cannot create directory: %s", err)
+	}
+	defer os.RemoveAll(empty)
+	other := t.TempDir()
+	if err := os.WriteFile(filepath.Join(other, "go.mod"), []byte("module example.com/other\n\ngo 1.22\n"), 0600); err != nil {
+		t.Fatalf("cannot write go.mod: %s", err)
+	}
+	otherEmpty := filepath.Join(other, "api", "v1")
+	if err := os.MkdirAll(otherEmpty, 0700); err != nil {
+		t.Fatalf("cannot create directory: %s", err)
+	}
+	type want struct {
+		path string
+		err  bool
+	}
+	cases := map[string]struct {
+		reason string
+		path   string
+		want
+	}{
+		"ExistingPackage": {
+			reason: "The module of an existing package should be returned.",
+			path:   ".",
+			want:   want{path: "github.com/muvaf/typewriter"},
+		},
+		"EmptyDirectory": {
+			reason: "The module of a directory without Go files should be found using the closest go.mod file.",
+			path:   empty,
+			want:   want{path: "github.com/muvaf/typewriter"},
+		},
+		"OtherModule": {
+			reason: "The closest go.mod file should be used even if it's not the main module.",
+			path:   otherEmpty,
+			want:   want{path: "example.com/other"},
+		},
+		"NoModule": {
+			reason: "An error should be returned if there is no module at all.",
+			path:   t.TempDir(),
+			want:   want{err: true},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := NewCache().GetModulePath(tc.path)
+			if (err != nil) != tc.want.err {
+				t.Fatalf("\n%s\nGetModulePath(...): unexpected error: %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.path, got); diff != "" {
+				t.Errorf("\n%s\nGetModulePath(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCacheGetTypeWithFullPath(t *testing.T) {
+	p := test.ParsePackage(`
+package test
+
+type Instance struct {
+	Name string
+}
+
+type List[T any] []T
+`)
+	type want struct {
+		name string
+		err  bool
+	}
+	cases := map[string]struct {
+		reason string
+		path   string
+		want
+	}{
+		"Type": {
+			reason: "The type should be returned by its full path.",
+			path:   "simple.go.Instance",
+			want:   want{name: "simple.go.Instance"},
+		},
+		"Instance": {
+			reason: "Generic types should be instantiated with the given type arguments.",
+			path:   "simple.go.List[simple.go.Instance]",
+			want:   want{name: "simple.go.List[simple.go.Instance]"},
+		},
+		"NoPackagePath": {
+			reason: "An error should be returned if there is no package path instead of panicking.",
+			path:   "Instance",
+			want:   want{err: true},
+		},
+		"EmptyPackagePath": {
+			reason: "An error should be returned if the package path is empty.",
+			path:   ".Instance",
+			want:   want{err: true},
+		},
+		"NoTypeName": {
+			reason: "An error should be returned if the type name is empty.",
+			path:   "simple.go.",
+			want:   want{err: true},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := NewCache(p).GetTypeWithFullPath(tc.path)
+			if (err != nil) != tc.want.err {
+				t.Fatalf("\n%s\nGetTypeWithFullPath(...): unexpected error: %v", tc.reason, err)
+			}
+			if tc.want.err {
+				return
+			}
+			if diff := cmp.Diff(tc.want.name, got.String()); diff != "" {
+				t.Errorf("\n%s\nGetTypeWithFullPath(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
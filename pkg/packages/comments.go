@@ -55,11 +55,29 @@ type CommentCache struct {
 }
 
 func (cc *CommentCache) GetPackageComments(pkgPath string) (Comments, error) {
+	if c, ok := cc.cache[pkgPath]; ok {
+		return c, nil
+	}
 	p, err := cc.pkgCache.GetPackage(pkgPath)
 	if err != nil {
 		return Comments{}, errors.Wrapf(err, "cannot get package %s", pkgPath)
 	}
-	return LoadComments(p), nil
+	cc.cache[pkgPath] = LoadComments(p)
+	return cc.cache[pkgPath], nil
+}
+
+// CommentOf returns the comment of given object by loading the comments of its
+// package. Objects without a valid position, like the ones constructed during
+// generation, don't have comments.
+func (cc *CommentCache) CommentOf(o types.Object) (string, error) {
+	if o.Pkg() == nil || !o.Pos().IsValid() {
+		return "", nil
+	}
+	c, err := cc.GetPackageComments(o.Pkg().Path())
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot get comments of package %s", o.Pkg().Path())
+	}
+	return c.CommentOf(o), nil
 }
 
 func LoadComments(p *packages.Package) Comments {
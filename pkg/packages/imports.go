@@ -16,6 +16,8 @@ package packages
 
 import (
 	"fmt"
+	"go/token"
+	"go/types"
 	"strings"
 )
 
@@ -24,92 +26,254 @@ func NewImports(pkgPath, pkgName string) *Imports {
 		PackagePath: pkgPath,
 		PackageName: pkgName,
 		Imports:     map[string]string{},
+		Names:       map[string]string{},
+		Reserved:    map[string]struct{}{},
 	}
 }
 
 type Imports struct {
 	PackagePath string
 	PackageName string
-	Imports     map[string]string
+	// Imports holds the aliases of the imported packages indexed by their
+	// paths.
+	Imports map[string]string
+	// Names holds the names of the imported packages indexed by their paths
+	// if they're known, i.e. taken from types.Package.
+	Names map[string]string
+	// Reserved holds the identifiers that cannot be used as aliases, like the
+	// ones declared in the package or the parameters of generated functions.
+	Reserved map[string]struct{}
+	// ReservedPrefixes holds the prefixes of the identifiers that cannot be
+	// used as aliases if they're followed by a number, like the loop variables
+	// of generated functions.
+	ReservedPrefixes map[string]struct{}
+}
+
+// Reserve makes sure the given identifiers are not used as aliases of the
+// packages imported after the call.
+func (m *Imports) Reserve(names ...string) {
+	if m.Reserved == nil {
+		m.Reserved = map[string]struct{}{}
+	}
+	for _, n := range names {
+		m.Reserved[n] = struct{}{}
+	}
+}
+
+// ReserveNumbered makes sure the identifiers made of any of the given prefixes
+// followed by a number, like v0 and v1 for prefix v, are not used as aliases of
+// the packages imported after the call.
+func (m *Imports) ReserveNumbered(prefixes ...string) {
+	if m.ReservedPrefixes == nil {
+		m.ReservedPrefixes = map[string]struct{}{}
+	}
+	for _, p := range prefixes {
+		m.ReservedPrefixes[p] = struct{}{}
+	}
+}
+
+// isReserved returns true if the identifier is reserved either by itself or
+// by its prefix.
+func (m *Imports) isReserved(name string) bool {
+	if _, ok := m.Reserved[name]; ok {
+		return true
+	}
+	for p := range m.ReservedPrefixes {
+		if n := strings.TrimPrefix(name, p); n != name && n != "" && strings.Trim(n, "0123456789") == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ReserveScope reserves all identifiers declared in the scope, which is
+// usually the scope of the package the file belongs to.
+func (m *Imports) ReserveScope(s *types.Scope) {
+	m.Reserve(s.Names()...)
 }
 
 // TODO(muvaf): We could make this routine-safe but it's not necessary for now.
 
 // UseType adds the package of given type to the import map and returns the alias
 // you can use in that Go file.
+//
+// Deprecated: UseType parses the string form of the type, which doesn't work
+// for all types. Use TypeString instead.
 func (m *Imports) UseType(in string) string {
+	if base, args, ok := splitTypeArgs(in); ok {
+		for i, a := range args {
+			args[i] = m.UseType(a)
+		}
+		return fmt.Sprintf("%s[%s]", m.UseType(base), strings.Join(args, ", "))
+	}
 	if strings.HasPrefix(in, "map") {
-		keyType := calculateTypeNameAndAlias(in[strings.Index(in, "[")+1:strings.Index(in, "]")], m.PackagePath, m.Imports)
-		valueType := calculateTypeNameAndAlias(in[strings.Index(in, "]")+1:], m.PackagePath, m.Imports)
+		keyType := calculateTypeNameAndAlias(in[strings.Index(in, "[")+1:strings.Index(in, "]")], m)
+		valueType := m.UseType(in[strings.Index(in, "]")+1:])
 		return fmt.Sprintf("map[%s]%s", keyType, valueType)
 	}
-	return calculateTypeNameAndAlias(in, m.PackagePath, m.Imports)
+	return calculateTypeNameAndAlias(in, m)
 }
 
-func calculateTypeNameAndAlias(in, packagePath string, imports map[string]string) string {
+func calculateTypeNameAndAlias(in string, m *Imports) string {
 	pkgPath, typeNameFmt := parseTypeDec(in)
 	if isBuiltIn(typeNameFmt) {
 		return in
 	}
-	if strings.HasSuffix(packagePath, pkgPath) {
-		// this is a temp hack for my own code :(
-		return strings.ReplaceAll(typeNameFmt, "%s.", "")
+	return fmt.Sprintf(strings.ReplaceAll(typeNameFmt, "%s.", "%s"), m.UsePackage(pkgPath))
+}
+
+// Qualifier returns a types.Qualifier that adds the packages it's called with
+// to the import map and returns their aliases. The current package is not
+// qualified.
+func (m *Imports) Qualifier() types.Qualifier {
+	return func(p *types.Package) string {
+		return strings.TrimSuffix(m.usePackage(p.Path(), p.Name()), ".")
 	}
-	val, ok := imports[pkgPath]
-	if ok {
-		return fmt.Sprintf(typeNameFmt, val)
+}
+
+// TypeString returns the type as it should be written in the Go file with the
+// packages of all named types it refers to added to the import map.
+func (m *Imports) TypeString(t types.Type) string {
+	return types.TypeString(t, m.Qualifier())
+}
+
+// TypeParamsString returns the declaration of given type parameters with their
+// constraints written by given function and the type argument list that
+// refers to them, i.e. "[K comparable, V any]" and "[K, V]". Both are empty if
+// there are no type parameters.
+func TypeParamsString(tps *types.TypeParamList, typeString func(types.Type) string) (string, string) {
+	if tps.Len() == 0 {
+		return "", ""
 	}
-	tmp := map[string]struct{}{}
-	for _, a := range imports {
-		tmp[a] = struct{}{}
+	decl := make([]string, tps.Len())
+	args := make([]string, tps.Len())
+	for i := 0; i < tps.Len(); i++ {
+		tp := tps.At(i)
+		args[i] = tp.Obj().Name()
+		decl[i] = fmt.Sprintf("%s %s", tp.Obj().Name(), typeString(tp.Constraint()))
 	}
-	words := strings.Split(pkgPath, "/")
-	alias := words[len(words)-1]
-	for i := len(words) - 2; i >= 0; i-- {
-		if _, ok := tmp[alias]; !ok {
-			break
-		}
-		alias += strings.ReplaceAll(words[i], ".", "")
+	return fmt.Sprintf("[%s]", strings.Join(decl, ", ")), fmt.Sprintf("[%s]", strings.Join(args, ", "))
+}
+
+// ObjectString returns the name of the package-level object qualified with the
+// alias of its package, like "v1.Instance" for a type.
+func (m *Imports) ObjectString(obj types.Object) string {
+	if obj.Pkg() == nil {
+		return obj.Name()
 	}
-	// Because the main map guarantees to have each of its entry to be different,
-	// the for loop above has to find a meaningful result before running out.
-	// The ReplaceAll statement is pinching hole in this completeness, but considering
-	// the paths are URLs, replacing dot with nothing should be fine.
-	imports[pkgPath] = alias
-	return fmt.Sprintf(typeNameFmt, alias)
+	return m.usePackage(obj.Pkg().Path(), obj.Pkg().Name()) + obj.Name()
 }
 
 // UsePackage adds the package to the import map and returns the alias you
 // can use in that Go file. The returned package name will have "." as suffix
-// if it is from a different package than the current one.
+// if it is from a different package than the current one. Since only the path
+// is known, the name of the package is assumed to be the last element of the
+// path.
 func (m *Imports) UsePackage(pkgPath string) string {
-	if strings.HasSuffix(m.PackagePath, pkgPath) {
+	return m.usePackage(pkgPath, "")
+}
+
+// usePackage returns the alias of the package with "." as suffix. The alias
+// is the name of the package unless it's taken by another package, a reserved
+// identifier, a keyword or a predeclared identifier, in which case it's
+// prefixed with the elements of the path until it's not taken.
+func (m *Imports) usePackage(pkgPath, name string) string {
+	if pkgPath == "" || pkgPath == m.PackagePath {
 		return ""
 	}
+	if name != "" {
+		if m.Names == nil {
+			m.Names = map[string]string{}
+		}
+		m.Names[pkgPath] = name
+	}
 	val, ok := m.Imports[pkgPath]
 	if ok {
 		return val + "."
 	}
-	tmp := map[string]struct{}{}
+	taken := map[string]struct{}{}
 	for _, a := range m.Imports {
-		tmp[a] = struct{}{}
+		taken[a] = struct{}{}
+	}
+	isTaken := func(alias string) bool {
+		_, imported := taken[alias]
+		return imported || m.isReserved(alias) || token.IsKeyword(alias) || types.Universe.Lookup(alias) != nil
 	}
 	words := strings.Split(pkgPath, "/")
-	alias := words[len(words)-1]
-	for i := len(words) - 2; i >= 0; i-- {
-		if _, ok := tmp[alias]; !ok {
-			break
-		}
-		alias += strings.ReplaceAll(words[i], ".", "")
+	alias := name
+	if alias == "" {
+		alias = identifier(words[len(words)-1])
+	}
+	for i := len(words) - 2; i >= 0 && isTaken(alias); i-- {
+		alias = identifier(words[i]) + alias
+	}
+	// The elements of the path are unique as a whole but the identifiers made
+	// of them may not be, like "go-cmp" and "gocmp".
+	base := alias
+	for i := 2; isTaken(alias); i++ {
+		alias = fmt.Sprintf("%s%d", base, i)
 	}
-	// Because the main map guarantees to have each of its entry to be different,
-	// the for loop above has to find a meaningful result before running out.
-	// The ReplaceAll statement is pinching hole in this completeness, but considering
-	// the paths are URLs, replacing dot with nothing should be fine.
 	m.Imports[pkgPath] = alias
 	return alias + "."
 }
 
+// identifier returns the string with the characters that are not allowed in
+// Go identifiers removed.
+func identifier(s string) string {
+	b := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if isIdentChar(s[i]) {
+			b.WriteByte(s[i])
+		}
+	}
+	if b.Len() == 0 || ('0' <= b.String()[0] && b.String()[0] <= '9') {
+		return "pkg" + b.String()
+	}
+	return b.String()
+}
+
+// splitTypeArgs splits an instantiated generic type into its base type and type
+// arguments, i.e. "[]*github.com/org/repo/v1.List[int, string]" results in
+// "[]*github.com/org/repo/v1.List" and ["int", "string"].
+func splitTypeArgs(in string) (string, []string, bool) {
+	if strings.HasPrefix(in, "map[") || !strings.HasSuffix(in, "]") {
+		return "", nil, false
+	}
+	// The opening bracket of type arguments is the first one that comes right
+	// after an identifier, as opposed to the ones of slice and array types.
+	open := -1
+	for i := 1; i < len(in); i++ {
+		if in[i] == '[' && isIdentChar(in[i-1]) {
+			open = i
+			break
+		}
+	}
+	if open == -1 {
+		return "", nil, false
+	}
+	var args []string
+	depth, start := 0, open+1
+	for i := open + 1; i < len(in)-1; i++ {
+		switch in[i] {
+		case '[', '(', '{':
+			depth++
+		case ']', ')', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(in[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	args = append(args, strings.TrimSpace(in[start:len(in)-1]))
+	return in[:open], args, true
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
 // parseTypeDec returns the full package name and the type that can be used in
 // the code. You need to use formatter to replace %s in the type name with alias
 // that's used.
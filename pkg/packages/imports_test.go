@@ -15,6 +15,8 @@
 package packages
 
 import (
+	"go/types"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -147,6 +149,35 @@ func TestImports_UseType(t *testing.T) {
 				typeName: "map[v1alpha1.ExampleStruct]v1beta1.ExampleStruct1",
 			},
 		},
+		"GenericInstance": {
+			args: args{
+				m:  imports(),
+				in: "[]*github.com/org/repo/v1alpha1.List[int, github.com/org/repo/v1beta1.ExampleStruct]",
+			},
+			want: want{
+				m: imports(
+					importsWithImportsMap(map[string]string{
+						"github.com/org/repo/v1alpha1": "v1alpha1",
+						"github.com/org/repo/v1beta1":  "v1beta1",
+					}),
+				),
+				typeName: "[]*v1alpha1.List[int, v1beta1.ExampleStruct]",
+			},
+		},
+		"MapOfGenericInstance": {
+			args: args{
+				m:  imports(),
+				in: "map[string]github.com/org/repo/v1alpha1.List[github.com/org/repo/v1alpha1.Pair[string, int]]",
+			},
+			want: want{
+				m: imports(
+					importsWithImportsMap(map[string]string{
+						"github.com/org/repo/v1alpha1": "v1alpha1",
+					}),
+				),
+				typeName: "map[string]v1alpha1.List[v1alpha1.Pair[string, int]]",
+			},
+		},
 	}
 	for n, tc := range cases {
 		t.Run(n, func(t *testing.T) {
@@ -160,3 +191,194 @@ func TestImports_UseType(t *testing.T) {
 		})
 	}
 }
+
+func TestImportsTypeString(t *testing.T) {
+	v1 := types.NewPackage("github.com/org/repo/v1alpha1", "v1alpha1")
+	v2 := types.NewPackage("github.com/org/repo/v1beta1", "v1beta1")
+	example := types.NewNamed(types.NewTypeName(0, v1, "ExampleStruct", nil), types.NewStruct(nil, nil), nil)
+	other := types.NewNamed(types.NewTypeName(0, v2, "ExampleStruct", nil), types.NewStruct(nil, nil), nil)
+	tparam := types.NewTypeParam(types.NewTypeName(0, v1, "T", nil), types.Universe.Lookup("any").Type())
+	list := types.NewNamed(types.NewTypeName(0, v1, "List", nil), nil, nil)
+	list.SetTypeParams([]*types.TypeParam{tparam})
+	list.SetUnderlying(types.NewSlice(tparam))
+	inst, err := types.Instantiate(nil, list, []types.Type{types.NewPointer(other)}, true)
+	if err != nil {
+		t.Fatalf("cannot instantiate: %s", err)
+	}
+	cases := map[string]struct {
+		in      types.Type
+		out     string
+		imports map[string]string
+	}{
+		"Builtin": {
+			in:      types.Universe.Lookup("error").Type(),
+			out:     "error",
+			imports: map[string]string{},
+		},
+		"NestedMap": {
+			in:      types.NewMap(types.Typ[types.String], types.NewMap(example, types.NewSlice(other))),
+			out:     "map[string]map[v1alpha1.ExampleStruct][]v1beta1.ExampleStruct",
+			imports: map[string]string{"github.com/org/repo/v1alpha1": "v1alpha1", "github.com/org/repo/v1beta1": "v1beta1"},
+		},
+		"FuncWithChan": {
+			in: types.NewSignatureType(nil, nil, nil,
+				types.NewTuple(types.NewVar(0, nil, "in", types.NewChan(types.RecvOnly, example))),
+				types.NewTuple(types.NewVar(0, nil, "", types.Universe.Lookup("error").Type())), false),
+			out:     "func(in <-chan v1alpha1.ExampleStruct) error",
+			imports: map[string]string{"github.com/org/repo/v1alpha1": "v1alpha1"},
+		},
+		"GenericInstance": {
+			in:      types.NewMap(types.Typ[types.String], inst),
+			out:     "map[string]v1alpha1.List[*v1beta1.ExampleStruct]",
+			imports: map[string]string{"github.com/org/repo/v1alpha1": "v1alpha1", "github.com/org/repo/v1beta1": "v1beta1"},
+		},
+		"LocalType": {
+			in:      types.NewPointer(types.NewNamed(types.NewTypeName(0, types.NewPackage("github.com/org/repo/local", "local"), "Local", nil), types.Typ[types.Int], nil)),
+			out:     "*Local",
+			imports: map[string]string{},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := NewImports("github.com/org/repo/local", "local")
+			if diff := cmp.Diff(tc.out, m.TypeString(tc.in)); diff != "" {
+				t.Errorf("TypeString(...): -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.imports, m.Imports); diff != "" {
+				t.Errorf("TypeString(...): imports: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTypeParamsString(t *testing.T) {
+	v1 := types.NewPackage("github.com/org/repo/v1alpha1", "v1alpha1")
+	stringer := types.NewNamed(types.NewTypeName(0, v1, "Stringer", nil), types.NewInterfaceType(nil, nil), nil)
+	k := types.NewTypeParam(types.NewTypeName(0, v1, "K", nil), types.Universe.Lookup("comparable").Type())
+	v := types.NewTypeParam(types.NewTypeName(0, v1, "V", nil), stringer)
+	generic := types.NewNamed(types.NewTypeName(0, v1, "Map", nil), nil, nil)
+	generic.SetTypeParams([]*types.TypeParam{k, v})
+	generic.SetUnderlying(types.NewMap(k, v))
+	cases := map[string]struct {
+		in      *types.Named
+		decl    string
+		args    string
+		imports map[string]string
+	}{
+		"NotGeneric": {
+			in:      stringer,
+			imports: map[string]string{},
+		},
+		"Generic": {
+			in:      generic,
+			decl:    "[K comparable, V v1alpha1.Stringer]",
+			args:    "[K, V]",
+			imports: map[string]string{"github.com/org/repo/v1alpha1": "v1alpha1"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := NewImports("github.com/org/repo/local", "local")
+			decl, args := TypeParamsString(tc.in.TypeParams(), m.TypeString)
+			if diff := cmp.Diff(tc.decl, decl); diff != "" {
+				t.Errorf("TypeParamsString(...): declaration: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.args, args); diff != "" {
+				t.Errorf("TypeParamsString(...): arguments: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.imports, m.Imports); diff != "" {
+				t.Errorf("TypeParamsString(...): imports: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestImportsSuffixPackage(t *testing.T) {
+	timePkg := types.NewPackage("time", "time")
+	dbPkg := types.NewPackage("example.com/db", "db")
+	cases := map[string]struct {
+		pkgPath string
+		in      types.Type
+		out     string
+		imports map[string]string
+	}{
+		"Stdlib": {
+			pkgPath: "example.com/app/api/time",
+			in:      types.NewNamed(types.NewTypeName(0, timePkg, "Time", nil), types.NewStruct(nil, nil), nil),
+			out:     "time.Time",
+			imports: map[string]string{"time": "time"},
+		},
+		"PathSuffix": {
+			pkgPath: "example.com/userdb",
+			in:      types.NewNamed(types.NewTypeName(0, dbPkg, "Conn", nil), types.NewStruct(nil, nil), nil),
+			out:     "db.Conn",
+			imports: map[string]string{"example.com/db": "db"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := NewImports(tc.pkgPath, tc.pkgPath[strings.LastIndex(tc.pkgPath, "/")+1:])
+			if diff := cmp.Diff(tc.out, m.TypeString(tc.in)); diff != "" {
+				t.Errorf("TypeString(...): -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.imports, m.Imports); diff != "" {
+				t.Errorf("TypeString(...): imports: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestImportsAliases(t *testing.T) {
+	cases := map[string]struct {
+		reserved []string
+		numbered []string
+		pkgs     []*types.Package
+		want     map[string]string
+	}{
+		"PackageName": {
+			pkgs: []*types.Package{types.NewPackage("gopkg.in/yaml.v3", "yaml")},
+			want: map[string]string{"gopkg.in/yaml.v3": "yaml"},
+		},
+		"OtherPackage": {
+			pkgs: []*types.Package{
+				types.NewPackage("k8s.io/api/core/v1", "v1"),
+				types.NewPackage("k8s.io/api/apps/v1", "v1"),
+			},
+			want: map[string]string{"k8s.io/api/core/v1": "v1", "k8s.io/api/apps/v1": "appsv1"},
+		},
+		"ReservedIdentifier": {
+			reserved: []string{"db"},
+			pkgs:     []*types.Package{types.NewPackage("github.com/org/repo/db", "db")},
+			want:     map[string]string{"github.com/org/repo/db": "repodb"},
+		},
+		"NumberedIdentifier": {
+			numbered: []string{"v", "k"},
+			pkgs: []*types.Package{
+				types.NewPackage("k8s.io/api/core/v1", "v1"),
+				types.NewPackage("k8s.io/client-go/kubernetes", "k8s"),
+				types.NewPackage("github.com/org/repo/v", "v"),
+			},
+			want: map[string]string{"k8s.io/api/core/v1": "corev1", "k8s.io/client-go/kubernetes": "k8s", "github.com/org/repo/v": "v"},
+		},
+		"KeywordAndPredeclared": {
+			pkgs: []*types.Package{
+				types.NewPackage("github.com/org/repo/type", "type"),
+				types.NewPackage("github.com/org/repo/string", "string"),
+			},
+			want: map[string]string{"github.com/org/repo/type": "repotype", "github.com/org/repo/string": "repostring"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := NewImports("github.com/org/repo/local", "local")
+			m.Reserve(tc.reserved...)
+			m.ReserveNumbered(tc.numbered...)
+			for _, p := range tc.pkgs {
+				m.Qualifier()(p)
+			}
+			if diff := cmp.Diff(tc.want, m.Imports); diff != "" {
+				t.Errorf("Qualifier(): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -16,46 +16,133 @@ package packages
 
 import (
 	"fmt"
+	"go/types"
+	"sort"
 	"strings"
+
+	"golang.org/x/tools/go/packages"
 )
 
 const (
 	CommentPrefix = "+typewriter"
+
+	// SectionTypes is the section of the markers placed on types.
+	SectionTypes = "types"
+
+	// TypesAggregated is the full path of a type that the marked type is an
+	// aggregate of, i.e. +typewriter:types:aggregated=<package path>.<type name>
+	// It can be given multiple times.
+	TypesAggregated = "aggregated"
+
+	// SectionField is the section of the markers placed on struct fields.
+	SectionField = "field"
+
+	// FieldExpression is the Go expression that the field is computed from,
+	// i.e. +typewriter:field:expr=a.Name + " " + a.Surname
+	FieldExpression = "expr"
+
+	// FieldDefault is the Go literal that the field is set to before the
+	// values from the source are assigned, i.e. +typewriter:field:default="default"
+	// or +typewriter:field:default=StatusActive with a constant of the package.
+	FieldDefault = "default"
+
+	// FieldIgnore marks the field to be ignored when its type is copied. With
+	// a value, it's a glob pattern for the fields to ignore, i.e.
+	// +typewriter:field:ignore=**.Spec:Secret
+	FieldIgnore = "ignore"
+
+	// TypesFieldOrder is the comma-separated list of field names in the order
+	// they should be printed, i.e. +typewriter:types:order=Name,Surname,ID
+	// It can be given multiple times.
+	TypesFieldOrder = "order"
+
+	// TypesRename is the name that the local copy of the marked type should
+	// have, i.e. +typewriter:types:rename=EC2Instance
+	TypesRename = "rename"
+
+	// TypesIgnore marks the type to be ignored when it's copied. With a value,
+	// it's a glob pattern for the types to ignore, i.e.
+	// +typewriter:types:ignore=**.Internal*
+	TypesIgnore = "ignore"
 )
 
 func NewCommentMarkers(c string) CommentMarkers {
 	return CommentMarkers{
 		Comment:         c,
-		SectionContents: map[string]map[string]string{},
+		SectionContents: map[string]map[string][]string{},
 	}
 }
 
 type CommentMarkers struct {
 	// SectionContents holds the equality pairs and indexed by the string until
-	// the last ":".
-	// For example, the following two lines:
+	// the last ":". A key can have multiple values.
+	// For example, the following three lines:
 	// +typewriter:types:key1=val1
 	// +typewriter:types:key2=val2
+	// +typewriter:types:key2=val3
 	// would be indexed as following:
 	// {
-	//    "types": {"key1":"val1", "key2":"val2"}
+	//    "types": {"key1": ["val1"], "key2": ["val2", "val3"]}
 	// }
-	SectionContents map[string]map[string]string
+	SectionContents map[string]map[string][]string
 
 	// Comment is the original comment string.
 	Comment string
 }
 
+// Get returns the last value given for the key in the section.
+func (ct CommentMarkers) Get(section, key string) (string, bool) {
+	vals := ct.SectionContents[section][key]
+	if len(vals) == 0 {
+		return "", false
+	}
+	return vals[len(vals)-1], true
+}
+
+// Values returns all values given for the key in the section.
+func (ct CommentMarkers) Values(section, key string) []string {
+	return ct.SectionContents[section][key]
+}
+
+// Add appends the value to the ones given for the key in the section.
+func (ct CommentMarkers) Add(section, key, value string) {
+	if _, ok := ct.SectionContents[section]; !ok {
+		ct.SectionContents[section] = map[string][]string{}
+	}
+	ct.SectionContents[section][key] = append(ct.SectionContents[section][key], value)
+}
+
+// Print returns the markers as comment lines with given prefix, sorted by
+// section and key for stable output.
 func (ct CommentMarkers) Print(prefix string) string {
+	sections := make([]string, 0, len(ct.SectionContents))
+	for section := range ct.SectionContents {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
 	out := ""
-	for section, pairs := range ct.SectionContents {
-		for k, v := range pairs {
-			out += fmt.Sprintf("\n// +%s:%s:%s=%s", prefix, section, k, v)
+	for _, section := range sections {
+		keys := make([]string, 0, len(ct.SectionContents[section]))
+		for k := range ct.SectionContents[section] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			for _, v := range ct.SectionContents[section][k] {
+				if v == "" {
+					out += fmt.Sprintf("\n// %s:%s:%s", prefix, section, k)
+					continue
+				}
+				out += fmt.Sprintf("\n// %s:%s:%s=%s", prefix, section, k, v)
+			}
 		}
 	}
 	return out
 }
 
+// NewCommentMarkersFromText parses the lines of given comment that start with
+// the prefix, i.e. "+typewriter:types:key=val" with "+typewriter" as prefix
+// results in "types" section with key "key" and value "val".
 func NewCommentMarkersFromText(c string, prefix string) CommentMarkers {
 	if !strings.Contains(c, prefix) {
 		return CommentMarkers{}
@@ -63,21 +150,44 @@ func NewCommentMarkersFromText(c string, prefix string) CommentMarkers {
 	ct := NewCommentMarkers(c)
 	lines := strings.Split(c, "\n")
 	for _, l := range lines {
-		if !strings.Contains(l, prefix) {
+		// Comments stored with the generated types keep their "//".
+		l = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(l), "//"))
+		if !strings.HasPrefix(l, prefix) {
 			continue
 		}
-		l = strings.TrimPrefix(l, prefix)
-		sections := strings.Split(l, ":")
-		sectionKey := strings.Join(sections[:len(sections)-1], ":")
-		pairs := strings.Split(sections[len(sections)-1], "=")
-		if len(ct.SectionContents[sectionKey]) == 0 {
-			ct.SectionContents[sectionKey] = map[string]string{}
-		}
+		l = strings.TrimPrefix(strings.TrimPrefix(l, prefix), ":")
+		// Values can contain any character, like Go expressions, so only the
+		// part before the first "=" is split into sections.
+		pairs := strings.SplitN(l, "=", 2)
+		sections := strings.Split(pairs[0], ":")
 		val := ""
 		if len(pairs) > 1 {
 			val = pairs[1]
 		}
-		ct.SectionContents[sectionKey][pairs[0]] = val
+		ct.Add(strings.Join(sections[:len(sections)-1], ":"), sections[len(sections)-1], val)
 	}
 	return ct
 }
+
+// LoadCommentMarkers returns the markers of the named types in the package
+// that have at least one marker.
+func LoadCommentMarkers(p *packages.Package) (map[*types.Named]*CommentMarkers, error) {
+	comments := LoadComments(p)
+	result := map[*types.Named]*CommentMarkers{}
+	for _, name := range p.Types.Scope().Names() {
+		tn, ok := p.Types.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		n, ok := tn.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		cm := NewCommentMarkersFromText(comments.CommentOf(tn), CommentPrefix)
+		if len(cm.SectionContents) == 0 {
+			continue
+		}
+		result[n] = &cm
+	}
+	return result, nil
+}
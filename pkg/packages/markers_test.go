@@ -0,0 +1,60 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packages
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestNewCommentMarkersFromText(t *testing.T) {
+	cases := map[string]struct {
+		comment string
+		want    map[string]map[string][]string
+	}{
+		"NoMarker": {
+			comment: "UserV1 is a user.\n",
+		},
+		"Sections": {
+			comment: "UserV1 is a user.\n+typewriter:types:key1=val1\n+typewriter:types:key2\n+typewriter:field:sub:key3=val3\n",
+			want: map[string]map[string][]string{
+				"types":     {"key1": {"val1"}, "key2": {""}},
+				"field:sub": {"key3": {"val3"}},
+			},
+		},
+		"MultipleValues": {
+			comment: "+typewriter:types:aggregated=example.com/db.UserV1\n+typewriter:types:aggregated=example.com/db.UserV2\n",
+			want: map[string]map[string][]string{
+				"types": {"aggregated": {"example.com/db.UserV1", "example.com/db.UserV2"}},
+			},
+		},
+		"ValueWithSeparators": {
+			comment: "+typewriter:field:expr=a.Name[1:] == \"x=y\"\n",
+			want: map[string]map[string][]string{
+				"field": {"expr": {"a.Name[1:] == \"x=y\""}},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := NewCommentMarkersFromText(tc.comment, CommentPrefix)
+			if diff := cmp.Diff(tc.want, got.SectionContents, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("NewCommentMarkersFromText(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
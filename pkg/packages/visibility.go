@@ -0,0 +1,49 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packages
+
+import (
+	"go/types"
+)
+
+// FieldVisibility is the policy that decides whether a struct field should be
+// used by the generated code.
+type FieldVisibility int
+
+const (
+	// VisibilityAuto includes unexported fields only if the generated code
+	// lives in the same package as the field, i.e. it has access to it.
+	VisibilityAuto FieldVisibility = iota
+	// VisibilityExported includes only the exported fields.
+	VisibilityExported
+	// VisibilityAll includes all fields regardless of where the generated code
+	// lives.
+	VisibilityAll
+)
+
+// Includes reports whether given field should be used by the code generated
+// in the package with given Go package path.
+func (v FieldVisibility) Includes(f *types.Var, pkgPath string) bool {
+	if f.Exported() {
+		return true
+	}
+	switch v {
+	case VisibilityAll:
+		return true
+	case VisibilityAuto:
+		return f.Pkg() != nil && pkgPath != "" && f.Pkg().Path() == pkgPath
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packages
+
+import (
+	"go/types"
+	"testing"
+)
+
+func TestFieldVisibilityIncludes(t *testing.T) {
+	pkg := types.NewPackage("example.com/db", "db")
+	unexported := types.NewField(0, pkg, "conn", types.Typ[types.String], false)
+	exported := types.NewField(0, pkg, "Conn", types.Typ[types.String], false)
+	type args struct {
+		v       FieldVisibility
+		f       *types.Var
+		pkgPath string
+	}
+	cases := map[string]struct {
+		args
+		want bool
+	}{
+		"Exported": {
+			args: args{v: VisibilityExported, f: exported, pkgPath: "example.com/app"},
+			want: true,
+		},
+		"UnexportedSamePackage": {
+			args: args{v: VisibilityAuto, f: unexported, pkgPath: "example.com/db"},
+			want: true,
+		},
+		"UnexportedOtherPackage": {
+			args: args{v: VisibilityAuto, f: unexported, pkgPath: "example.com/app"},
+			want: false,
+		},
+		"UnexportedSuffixPackage": {
+			args: args{v: VisibilityAuto, f: unexported, pkgPath: "example.com/userdb"},
+			want: false,
+		},
+		"UnexportedExportedOnly": {
+			args: args{v: VisibilityExported, f: unexported, pkgPath: "example.com/db"},
+			want: false,
+		},
+		"UnexportedAll": {
+			args: args{v: VisibilityAll, f: unexported, pkgPath: "example.com/app"},
+			want: true,
+		},
+	}
+	for n, tc := range cases {
+		t.Run(n, func(t *testing.T) {
+			if got := tc.v.Includes(tc.f, tc.pkgPath); got != tc.want {
+				t.Errorf("Includes(...) = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"reflect"
 
 	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
 )
 
 func EquateErrors() cmp.Option {
@@ -54,3 +55,31 @@ func ParseString(s string) *types.Scope {
 	}
 	return pkg.Scope()
 }
+
+// ParsePackage returns the package of given source together with its syntax
+// and type information, which are needed to read the comments and the code.
+func ParsePackage(s string) *packages.Package {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "simple.go", s, parser.ParseComments)
+	if err != nil {
+		panic(err)
+	}
+	cfg := types.Config{Importer: importer.Default()}
+	info := &types.Info{
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+	}
+	pkg, err := cfg.Check("simple.go", fset, []*ast.File{f}, info)
+	if err != nil {
+		panic(err)
+	}
+	return &packages.Package{
+		Name:      pkg.Name(),
+		PkgPath:   pkg.Path(),
+		Fset:      fset,
+		Syntax:    []*ast.File{f},
+		Types:     pkg,
+		TypesInfo: info,
+	}
+}
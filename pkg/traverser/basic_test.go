@@ -86,7 +86,7 @@ func TestPrint(t *testing.T) {
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
 			b := NewBasic()
-			result, err := b.Print(tc.args.a, tc.args.b, tc.args.aPath, tc.args.bPath)
+			result, err := b.Print(tc.args.a, tc.args.b, tc.args.aPath, tc.args.bPath, false)
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
 				t.Errorf("add: -want, +got:\n%s", diff)
 			}
@@ -0,0 +1,122 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traverser
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+
+	"github.com/pkg/errors"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+)
+
+// PrintDefaults returns the assignment statements for the fields of given type
+// and its nested structs that have a default value marker. The literals are
+// type-checked against the type of the field they are assigned to and the
+// packages they refer to are added to the imports. Nested pointer structs are
+// skipped since they are nil until the traversal creates them.
+func PrintDefaults(cc *packages.CommentCache, im *packages.Imports, n *types.Named, bFieldPath string) (string, error) {
+	return printDefaults(cc, im, n, bFieldPath, nil)
+}
+
+// printDefaults is PrintDefaults that doesn't descend into the nested structs
+// in the fields of skip, which are traversed and get their defaults on their
+// own.
+func printDefaults(cc *packages.CommentCache, im *packages.Imports, n *types.Named, bFieldPath string, skip map[*types.Var]struct{}) (string, error) {
+	st, ok := n.Underlying().(*types.Struct)
+	if !ok {
+		return "", nil
+	}
+	out := ""
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		path := fmt.Sprintf("%s.%s", bFieldPath, f.Name())
+		comment, err := cc.CommentOf(f)
+		if err != nil {
+			return "", errors.Wrapf(err, "cannot get comment of field %s", f.Name())
+		}
+		cm := packages.NewCommentMarkersFromText(comment, packages.CommentPrefix)
+		if lit, ok := cm.Get(packages.SectionField, packages.FieldDefault); ok && lit != "" {
+			if err := CheckLiteral(lit, f); err != nil {
+				return "", errors.Wrapf(err, "invalid default value for field %s", f.Name())
+			}
+			e, err := QualifyExpression(lit, f, im)
+			if err != nil {
+				return "", errors.Wrapf(err, "cannot qualify default value of field %s", f.Name())
+			}
+			out += fmt.Sprintf("\n%s = %s", path, e)
+			continue
+		}
+		if _, ok := skip[f]; ok {
+			continue
+		}
+		if nested, ok := f.Type().(*types.Named); ok {
+			add, err := printDefaults(cc, im, nested, path, nil)
+			if err != nil {
+				return "", errors.Wrapf(err, "cannot print default values of field %s", f.Name())
+			}
+			out += add
+		}
+	}
+	return out, nil
+}
+
+// CheckLiteral returns error if given literal cannot be assigned to the given
+// field, i.e. "default" to an int or 300 to an int8. The literal is evaluated
+// in the file the field is declared in if it's known, so it can refer to the
+// constants, types and imports of that file, like StatusActive.
+func CheckLiteral(lit string, f *types.Var) error {
+	// The literal is checked as if it's returned from a function with the
+	// type of the field as result type, which covers both assignability and
+	// representability.
+	pkg, pos := f.Pkg(), f.Pos()
+	typ, ok := "", false
+	if scope := fileScope(f); scope != nil {
+		typ, ok = fileTypeString(scope, f.Pkg(), f.Type())
+	}
+	if !ok {
+		// The type is made available to the expression under a fixed name
+		// if it cannot be written in the file of the field.
+		pkg, pos, typ = types.NewPackage("defaults", "defaults"), token.NoPos, "T"
+		pkg.Scope().Insert(types.NewTypeName(token.NoPos, pkg, typ, f.Type()))
+	}
+	expr := fmt.Sprintf("func() %s { return %s }()", typ, lit)
+	if _, err := types.Eval(token.NewFileSet(), pkg, pos, expr); err != nil {
+		return errors.Wrapf(err, "literal %s is not assignable to %s", lit, f.Type().String())
+	}
+	return nil
+}
+
+// fileTypeString returns the type as it's written in the file of given scope
+// using the names the packages are imported with. It returns false if any of
+// the packages is not imported in that file.
+func fileTypeString(scope *types.Scope, pkg *types.Package, t types.Type) (string, bool) {
+	ok := true
+	s := types.TypeString(t, func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		for _, n := range scope.Names() {
+			if pn, isPkg := scope.Lookup(n).(*types.PkgName); isPkg && pn.Imported() == p {
+				return n
+			}
+		}
+		ok = false
+		return p.Name()
+	})
+	return s, ok
+}
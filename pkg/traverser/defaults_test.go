@@ -0,0 +1,133 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traverser
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/muvaf/typewriter/pkg/test"
+)
+
+func TestCheckLiteral(t *testing.T) {
+	s := test.ParseString(`
+package test
+
+import "time"
+
+type Group string
+
+type Status string
+
+const StatusActive Status = "active"
+
+type User struct {
+	Name string
+}
+
+type Defaults struct {
+	String  string
+	Group   Group
+	Status  Status
+	User    User
+	Bool    bool
+	Int     int
+	Int8    int8
+	Pointer *string
+	Timeout time.Duration
+}
+`)
+	st := s.Lookup("Defaults").Type().Underlying().(*types.Struct)
+	field := func(name string) *types.Var {
+		for i := 0; i < st.NumFields(); i++ {
+			if st.Field(i).Name() == name {
+				return st.Field(i)
+			}
+		}
+		panic(name)
+	}
+	cases := map[string]struct {
+		lit   string
+		f     *types.Var
+		valid bool
+	}{
+		"String": {
+			lit:   `"default"`,
+			f:     field("String"),
+			valid: true,
+		},
+		"NamedString": {
+			lit:   `"default"`,
+			f:     field("Group"),
+			valid: true,
+		},
+		"Constant": {
+			lit:   `StatusActive`,
+			f:     field("Status"),
+			valid: true,
+		},
+		"ConstantMismatch": {
+			lit: `StatusActive`,
+			f:   field("Group"),
+		},
+		"CompositeLiteral": {
+			lit:   `User{Name: "default"}`,
+			f:     field("User"),
+			valid: true,
+		},
+		"Import": {
+			lit:   `5 * time.Second`,
+			f:     field("Timeout"),
+			valid: true,
+		},
+		"UnknownIdentifier": {
+			lit: `StatusUnknown`,
+			f:   field("Status"),
+		},
+		"Bool": {
+			lit:   `true`,
+			f:     field("Bool"),
+			valid: true,
+		},
+		"Mismatch": {
+			lit: `"default"`,
+			f:   field("Int"),
+		},
+		"Overflow": {
+			lit: `300`,
+			f:   field("Int8"),
+		},
+		"Pointer": {
+			lit: `"default"`,
+			f:   field("Pointer"),
+		},
+		"UnknownFile": {
+			lit:   `300`,
+			f:     types.NewField(0, nil, "Int", types.Typ[types.Int], false),
+			valid: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := CheckLiteral(tc.lit, tc.f)
+			if tc.valid && err != nil {
+				t.Errorf("CheckLiteral(...): unexpected error: %s", err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("CheckLiteral(...): expected error, got nil")
+			}
+		})
+	}
+}
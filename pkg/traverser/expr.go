@@ -0,0 +1,136 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traverser
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/ast/astutil"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+)
+
+const (
+	// SourceVariable is the identifier that refers to the source struct in
+	// the expressions given via markers.
+	SourceVariable = "a"
+)
+
+// Expression returns the expression given in the markers of the field, if any.
+func (f Field) Expression() (string, bool) {
+	e, ok := f.Markers.Get(packages.SectionField, packages.FieldExpression)
+	return e, ok && e != ""
+}
+
+// RewriteExpression replaces the source variable in given Go expression with
+// the path of the source struct so that the expression can be used in nested
+// structs as well, i.e. "a.Name" becomes "a.Belongings[v0].Name".
+func RewriteExpression(expr, aFieldPath string) (string, error) {
+	e, err := parser.ParseExpr(expr)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot parse expression %q", expr)
+	}
+	var rewriteErr error
+	result := astutil.Apply(e, func(c *astutil.Cursor) bool {
+		id, ok := c.Node().(*ast.Ident)
+		// Selectors and keys of composite literals are not variable references.
+		if !ok || id.Name != SourceVariable || c.Name() == "Sel" || c.Name() == "Key" {
+			return true
+		}
+		path, err := parser.ParseExpr(aFieldPath)
+		if err != nil {
+			rewriteErr = errors.Wrapf(err, "cannot parse field path %s", aFieldPath)
+			return false
+		}
+		c.Replace(path)
+		return false
+	}, nil)
+	if rewriteErr != nil {
+		return "", rewriteErr
+	}
+	out := &bytes.Buffer{}
+	if err := printer.Fprint(out, token.NewFileSet(), result); err != nil {
+		return "", errors.Wrap(err, "cannot print expression")
+	}
+	return out.String(), nil
+}
+
+// QualifyExpression makes the identifiers in given Go expression valid in the
+// generated file, i.e. the package of "strings.ToUpper(a.Name)" is added to
+// the imports and "StatusActive" declared next to the type becomes
+// "db.StatusActive". The identifiers are resolved in the file the given object
+// is declared in, which is usually the field that has the marker. The
+// expression is returned as is if that file is not known.
+func QualifyExpression(expr string, o types.Object, im *packages.Imports) (string, error) {
+	scope := fileScope(o)
+	if scope == nil {
+		return expr, nil
+	}
+	e, err := parser.ParseExpr(expr)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot parse expression %q", expr)
+	}
+	result := astutil.Apply(e, func(c *astutil.Cursor) bool {
+		switch n := c.Node().(type) {
+		case *ast.SelectorExpr:
+			x, ok := n.X.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			_, obj := scope.LookupParent(x.Name, token.NoPos)
+			pn, ok := obj.(*types.PkgName)
+			if !ok {
+				return true
+			}
+			// The package of the generated file doesn't need to be qualified.
+			if alias := im.Qualifier()(pn.Imported()); alias != "" {
+				c.Replace(&ast.SelectorExpr{X: ast.NewIdent(alias), Sel: n.Sel})
+			} else {
+				c.Replace(n.Sel)
+			}
+			return false
+		case *ast.Ident:
+			if n.Name == SourceVariable || c.Name() == "Sel" || c.Name() == "Key" {
+				return true
+			}
+			_, obj := scope.LookupParent(n.Name, token.NoPos)
+			if obj == nil || obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() {
+				return true
+			}
+			c.Replace(ast.NewIdent(im.ObjectString(obj)))
+		}
+		return true
+	}, nil)
+	out := &bytes.Buffer{}
+	if err := printer.Fprint(out, token.NewFileSet(), result); err != nil {
+		return "", errors.Wrap(err, "cannot print expression")
+	}
+	return out.String(), nil
+}
+
+// fileScope returns the scope of the file the object is declared in, which is
+// available only if its package is type-checked from source.
+func fileScope(o types.Object) *types.Scope {
+	if o == nil || o.Pkg() == nil || !o.Pos().IsValid() {
+		return nil
+	}
+	return o.Pkg().Scope().Innermost(o.Pos())
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traverser
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRewriteExpression(t *testing.T) {
+	type args struct {
+		expr       string
+		aFieldPath string
+	}
+	type want struct {
+		out string
+		err bool
+	}
+	cases := map[string]struct {
+		args
+		want
+	}{
+		"TopLevel": {
+			args: args{expr: `a.Name + " " + a.Surname`, aFieldPath: "a"},
+			want: want{out: `a.Name + " " + a.Surname`},
+		},
+		"Nested": {
+			args: args{expr: `a.Name + " " + a.Surname`, aFieldPath: "a.Belongings[v0]"},
+			want: want{out: `a.Belongings[v0].Name + " " + a.Belongings[v0].Surname`},
+		},
+		"Pointer": {
+			args: args{expr: `strings.ToUpper(a.Name)`, aFieldPath: "(*a.Owner)"},
+			want: want{out: `strings.ToUpper((*a.Owner).Name)`},
+		},
+		"SelectorNamedAsSource": {
+			args: args{expr: `a.a + x.a`, aFieldPath: "a.Inner"},
+			want: want{out: `a.Inner.a + x.a`},
+		},
+		"InvalidExpression": {
+			args: args{expr: `a.Name +`, aFieldPath: "a"},
+			want: want{err: true},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			out, err := RewriteExpression(tc.expr, tc.aFieldPath)
+			if (err != nil) != tc.want.err {
+				t.Fatalf("RewriteExpression(...): unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want.out, out); diff != "" {
+				t.Errorf("RewriteExpression(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
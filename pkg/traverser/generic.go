@@ -74,6 +74,48 @@ func WithNamed(n NamedTraverser) Option {
 	}
 }
 
+// WithFieldMatcher sets the FieldMatcher used to pair the fields of named
+// types.
+func WithFieldMatcher(m FieldMatcher) Option {
+	return func(g *Generic) {
+		g.namedOptions = append(g.namedOptions, func(n NamedTraverser) {
+			n.SetFieldMatcher(m)
+		})
+	}
+}
+
+// WithFieldMatchFns sets the functions used to pair the fields of named types.
+// They are tried in the given order, so a chain like exact name first and then
+// JSON tag name can be used for types that are named differently in Go.
+func WithFieldMatchFns(fns ...FieldMatchFn) Option {
+	return func(g *Generic) {
+		g.namedOptions = append(g.namedOptions, func(n NamedTraverser) {
+			n.SetFieldMatcher(NewFieldNameMatcher(fns...))
+		})
+	}
+}
+
+// WithCommentCache sets the cache used to fetch comments and markers of the
+// types and fields traversed.
+func WithCommentCache(cc *packages.CommentCache) Option {
+	return func(g *Generic) {
+		g.namedOptions = append(g.namedOptions, func(n NamedTraverser) {
+			n.SetCommentCache(cc)
+		})
+	}
+}
+
+// WithFieldVisibility sets the policy for using unexported fields of named
+// types. By default, they are used only if the generated code lives in the same
+// package as the field.
+func WithFieldVisibility(v packages.FieldVisibility) Option {
+	return func(g *Generic) {
+		g.namedOptions = append(g.namedOptions, func(n NamedTraverser) {
+			n.SetFieldVisibility(v)
+		})
+	}
+}
+
 func WithSlice(s SliceTraverser) Option {
 	return func(g *Generic) {
 		s.SetGenericTraverser(g)
@@ -93,7 +135,7 @@ func NewGeneric(im *packages.Imports, opts ...Option) *Generic {
 	g := &Generic{
 		Imports: im,
 		Slice:   NewSlice(im),
-		Named:   NewNamed(),
+		Named:   NewNamed(im),
 		Basic:   NewBasic(),
 		Map:     NewMap(im),
 		Pointer: NewPointer(im),
@@ -101,6 +143,11 @@ func NewGeneric(im *packages.Imports, opts ...Option) *Generic {
 	for _, f := range opts {
 		f(g)
 	}
+	// The settings of the named traverser are applied after all options so
+	// that they're kept if it's replaced with WithNamed.
+	for _, f := range g.namedOptions {
+		f(g.Named)
+	}
 	g.Slice.SetGenericTraverser(g)
 	g.Map.SetGenericTraverser(g)
 	g.Named.SetGenericTraverser(g)
@@ -115,6 +162,14 @@ type Generic struct {
 	Basic   BasicTraverser
 	Map     MapTraverser
 	Pointer PointerTraverser
+
+	namedOptions []func(NamedTraverser)
+}
+
+// SetDefaultValues makes the named traverser assign the default values given
+// via markers to every struct it produces.
+func (g *Generic) SetDefaultValues(cc *packages.CommentCache) {
+	g.Named.SetDefaultValues(cc)
 }
 
 func (g *Generic) Print(a, b types.Type, aFieldPath, bFieldPath string, levelNum int) (string, error) {
@@ -132,6 +187,11 @@ func (g *Generic) Print(a, b types.Type, aFieldPath, bFieldPath string, levelNum
 			o, err := g.Basic.Print(atb, btb, aFieldPath, bFieldPath, true)
 			return o, errors.Wrap(err, "cannot traverse basic pointer type")
 		}
+		// Type parameters are treated like basic types since we cannot know
+		// anything about their structure.
+		if _, ok := at.Elem().(*types.TypeParam); ok {
+			return g.Print(at.Elem(), bt.Elem(), aFieldPath, bFieldPath, levelNum)
+		}
 		// This is to guard for types like `*[]string` that are implicitly pointer
 		// of pointers. After processing that it's a pointer, we cannot proceed
 		// to process the element type without de-referencing the pointer.
@@ -173,6 +233,12 @@ func (g *Generic) Print(a, b types.Type, aFieldPath, bFieldPath string, levelNum
 		}
 		o, err := g.Basic.Print(at, bt, aFieldPath, bFieldPath, false)
 		return o, errors.Wrap(err, "cannot traverse basic type")
+	case *types.TypeParam:
+		bt, ok := b.(*types.TypeParam)
+		if !ok || at.Index() != bt.Index() {
+			return "", fmt.Errorf("not same type parameter at %s", bFieldPath)
+		}
+		return fmt.Sprintf("\n%s = %s", bFieldPath, aFieldPath), nil
 	case *types.Struct: // unnamed struct fields.
 		return "", nil
 	default:
@@ -14,7 +14,11 @@
 
 package traverser
 
-import "go/types"
+import (
+	"go/types"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+)
 
 type GenericTraverser interface {
 	Print(a, b types.Type, aFieldPath, bFieldPath string, levelNum int) (string, error)
@@ -28,8 +32,18 @@ type Templater interface {
 	SetTemplate(t string)
 }
 
+// DefaultValuesSetter is implemented by the traversers that assign the
+// default values given via markers to the structs they produce.
+type DefaultValuesSetter interface {
+	SetDefaultValues(cc *packages.CommentCache)
+}
+
 type NamedTraverser interface {
 	GenericCaller
+	DefaultValuesSetter
+	SetFieldMatcher(m FieldMatcher)
+	SetCommentCache(cc *packages.CommentCache)
+	SetFieldVisibility(v packages.FieldVisibility)
 	Print(a, b *types.Named, aFieldPath, bFieldPath string, levelNum int) (string, error)
 }
 
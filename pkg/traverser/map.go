@@ -33,10 +33,25 @@ const DefaultMapTmpl = `
 if len({{ .AFieldPath }}) != 0 {
   {{ .BFieldPath }} = make({{ .TypeB }}, len({{ .AFieldPath }}))
   for {{ .Key }} := range {{ .AFieldPath }} {
+{{- if .Value }}
+  {{ .Value }} := {{ .BFieldPath }}[{{ .Key }}]
+{{- end }}
 {{ .Statements }}
+{{- if .Value }}
+  {{ .BFieldPath }}[{{ .Key }}] = {{ .Value }}
+{{- end }}
   }
 }`
 
+// mapKeyPrefix is the prefix of the key variables of the loops over maps,
+// which is followed by the nesting level.
+const mapKeyPrefix = "k"
+
+// mapValuePrefix is the prefix of the variables that the struct values of
+// maps are filled in before they're stored in the map since the fields of
+// map elements cannot be assigned.
+const mapValuePrefix = "e"
+
 type DefaultMapTmplInput struct {
 	AFieldPath string
 	TypeA      string
@@ -69,17 +84,24 @@ func (m *Map) SetGenericTraverser(p GenericTraverser) {
 }
 
 func (m *Map) Print(a, b *types.Map, aFieldPath, bFieldPath string, levelNum int) (string, error) {
-	key := fmt.Sprintf("k%d", levelNum)
-	statements, err := m.Generic.Print(a.Elem(), b.Elem(), fmt.Sprintf("%s[%s]", aFieldPath, key), fmt.Sprintf("%s[%s]", bFieldPath, key), levelNum+1)
+	key := fmt.Sprintf("%s%d", mapKeyPrefix, levelNum)
+	bElemPath := fmt.Sprintf("%s[%s]", bFieldPath, key)
+	value := ""
+	if _, ok := b.Elem().Underlying().(*types.Struct); ok {
+		value = fmt.Sprintf("%s%d", mapValuePrefix, levelNum)
+		bElemPath = value
+	}
+	statements, err := m.Generic.Print(a.Elem(), b.Elem(), fmt.Sprintf("%s[%s]", aFieldPath, key), bElemPath, levelNum+1)
 	if err != nil {
 		return "", errors.Wrap(err, "cannot recursively traverse element type of slice")
 	}
 	i := DefaultMapTmplInput{
 		AFieldPath: aFieldPath,
-		TypeA:      m.Imports.UseType(a.String()),
+		TypeA:      m.Imports.TypeString(a),
 		BFieldPath: bFieldPath,
-		TypeB:      m.Imports.UseType(b.String()),
+		TypeB:      m.Imports.TypeString(b),
 		Key:        key,
+		Value:      value,
 		Statements: statements,
 	}
 	t, err := template.New("func").Parse(m.Template)
@@ -0,0 +1,158 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traverser
+
+import (
+	"go/types"
+	"reflect"
+	"strings"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+)
+
+const (
+	TagKeyJSON     = "json"
+	TagKeyYAML     = "yaml"
+	TagKeyProtobuf = "protobuf"
+)
+
+// Field is a struct field together with its tag, comment and markers.
+type Field struct {
+	Var     *types.Var
+	Tag     string
+	Comment string
+	Markers packages.CommentMarkers
+}
+
+// Struct is a named struct type together with its comment, markers and the
+// fields that are eligible for matching.
+type Struct struct {
+	Named   *types.Named
+	Comment string
+	Markers packages.CommentMarkers
+	Fields  []Field
+}
+
+// FieldPair is a pair of fields that will be traversed together.
+type FieldPair struct {
+	A Field
+	B Field
+}
+
+// FieldMatcher returns the pairs of fields of source struct a and target
+// struct b that should be traversed. The pairs are traversed in the order they
+// are returned.
+type FieldMatcher interface {
+	Match(a, b Struct) ([]FieldPair, error)
+}
+
+// NewFieldNameMatcher returns a FieldMatcher that tries given functions in
+// order for every field of the source struct and the first one that finds a
+// pair in the target struct wins.
+func NewFieldNameMatcher(fns ...FieldMatchFn) *FieldNameMatcher {
+	return &FieldNameMatcher{
+		MatchFns: fns,
+	}
+}
+
+// FieldNameMatcher pairs fields one-to-one using FieldMatchFns.
+type FieldNameMatcher struct {
+	MatchFns []FieldMatchFn
+}
+
+func (fm *FieldNameMatcher) Match(a, b Struct) ([]FieldPair, error) {
+	// A loose match function shouldn't be able to assign a target field twice.
+	paired := map[*types.Var]struct{}{}
+	var result []FieldPair
+	for _, af := range a.Fields {
+		bf, ok := fm.match(af, b.Fields, paired)
+		if !ok {
+			continue
+		}
+		paired[bf.Var] = struct{}{}
+		result = append(result, FieldPair{A: af, B: bf})
+	}
+	return result, nil
+}
+
+func (fm *FieldNameMatcher) match(af Field, bFields []Field, paired map[*types.Var]struct{}) (Field, bool) {
+	for _, fn := range fm.MatchFns {
+		for _, bf := range bFields {
+			if _, ok := paired[bf.Var]; ok {
+				continue
+			}
+			if fn(af.Var, bf.Var, af.Tag, bf.Tag) {
+				return bf, true
+			}
+		}
+	}
+	return Field{}, false
+}
+
+// FieldMatchFn reports whether field a of the source struct and field b of the
+// target struct should be paired. aTag and bTag are the raw struct tags of the
+// fields.
+type FieldMatchFn func(a, b *types.Var, aTag, bTag string) bool
+
+// MatchExactName pairs fields whose Go identifiers are the same.
+func MatchExactName() FieldMatchFn {
+	return func(a, b *types.Var, _, _ string) bool {
+		return a.Name() == b.Name()
+	}
+}
+
+// MatchCaseInsensitiveName pairs fields whose Go identifiers are the same when
+// case is ignored, i.e. "Id" and "ID".
+func MatchCaseInsensitiveName() FieldMatchFn {
+	return func(a, b *types.Var, _, _ string) bool {
+		return strings.EqualFold(a.Name(), b.Name())
+	}
+}
+
+// MatchTagName pairs fields whose names in the struct tag with given key are
+// the same, i.e. `json:"userId"` in both. Fields without the tag key or with
+// "-" as their name are never paired.
+func MatchTagName(key string) FieldMatchFn {
+	return func(_, _ *types.Var, aTag, bTag string) bool {
+		an, aok := TagName(aTag, key)
+		bn, bok := TagName(bTag, key)
+		return aok && bok && an == bn
+	}
+}
+
+// TagName returns the name given to the field in the struct tag with given key.
+// Protobuf tags carry the name in "name=" option while others have it as the
+// first comma-separated element.
+func TagName(tag, key string) (string, bool) {
+	val, ok := reflect.StructTag(tag).Lookup(key)
+	if !ok {
+		return "", false
+	}
+	name := ""
+	if key == TagKeyProtobuf {
+		for _, opt := range strings.Split(val, ",") {
+			if strings.HasPrefix(opt, "name=") {
+				name = strings.TrimPrefix(opt, "name=")
+				break
+			}
+		}
+	} else {
+		name = strings.Split(val, ",")[0]
+	}
+	if name == "" || name == "-" {
+		return "", false
+	}
+	return name, true
+}
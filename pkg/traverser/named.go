@@ -20,20 +20,55 @@ import (
 	"sort"
 
 	"github.com/pkg/errors"
+
+	"github.com/muvaf/typewriter/pkg/packages"
 )
 
-func NewNamed() *Named {
-	return &Named{}
+func NewNamed(im *packages.Imports) *Named {
+	return &Named{
+		Imports: im,
+		Matcher: NewFieldNameMatcher(MatchExactName()),
+	}
 }
 
 type Named struct {
+	Imports *packages.Imports
 	Generic GenericTraverser
+	Matcher FieldMatcher
+
+	// Visibility decides which fields are given to the matcher depending on
+	// the package the generated code lives in.
+	Visibility packages.FieldVisibility
+
+	// Comments is used to fetch the comments and markers of types and fields
+	// that are given to the matcher. If nil, they are empty.
+	Comments *packages.CommentCache
+
+	// Defaults is used to fetch the default value markers of the fields of the
+	// target types. Default values are not assigned if it's nil.
+	Defaults *packages.CommentCache
 }
 
 func (s *Named) SetGenericTraverser(p GenericTraverser) {
 	s.Generic = p
 }
 
+func (s *Named) SetFieldMatcher(m FieldMatcher) {
+	s.Matcher = m
+}
+
+func (s *Named) SetFieldVisibility(v packages.FieldVisibility) {
+	s.Visibility = v
+}
+
+func (s *Named) SetCommentCache(cc *packages.CommentCache) {
+	s.Comments = cc
+}
+
+func (s *Named) SetDefaultValues(cc *packages.CommentCache) {
+	s.Defaults = cc
+}
+
 func (s *Named) Print(a, b *types.Named, aFieldPath, bFieldPath string, levelNum int) (string, error) {
 	// TODO(muvaf): This could be *types.Map and valid.
 	at, aok := a.Underlying().(*types.Struct)
@@ -44,39 +79,130 @@ func (s *Named) Print(a, b *types.Named, aFieldPath, bFieldPath string, levelNum
 	if !bok {
 		return "", nil
 	}
-	// The list of fields look like sorted but actually isn't. So, we need to sort
-	// it for stable output.
-	aFields := make([]*types.Var, at.NumFields())
-	for i := 0; i < at.NumFields(); i++ {
-		aFields[i] = at.Field(i)
+	as, err := s.newStruct(a, at)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot collect fields of %s", a.Obj().Name())
+	}
+	bs, err := s.newStruct(b, bt)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot collect fields of %s", b.Obj().Name())
+	}
+	pairs, err := s.Matcher.Match(as, bs)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot match fields of %s and %s", a.Obj().Name(), b.Obj().Name())
 	}
-	sort.SliceStable(aFields, func(i, j int) bool {
-		return aFields[i].Name() < aFields[j].Name()
-	})
 	out := ""
-	for _, af := range aFields {
-		if af.Name() == "_" {
-			continue
+	if s.Defaults != nil {
+		// The nested structs that are paired get their defaults when they're
+		// traversed, which is after they're created if they're pointers.
+		paired := map[*types.Var]struct{}{}
+		for _, p := range pairs {
+			paired[p.B.Var] = struct{}{}
 		}
-		// TODO(muvaf): make this default but modifiable in the future.
-		if !af.Exported() {
-			continue
+		defaults, err := printDefaults(s.Defaults, s.Imports, b, bFieldPath, paired)
+		if err != nil {
+			return "", errors.Wrapf(err, "cannot print default values of %s", b.Obj().Name())
 		}
-		var bf *types.Var
-		for j := 0; j < bt.NumFields(); j++ {
-			if bt.Field(j).Name() == af.Name() {
-				bf = bt.Field(j)
-				break
-			}
+		out += defaults
+	}
+	computed := map[*types.Var]struct{}{}
+	for _, p := range pairs {
+		bPath := fmt.Sprintf("%s.%s", bFieldPath, p.B.Var.Name())
+		// The expression on the target field takes precedence over the one on
+		// the source field, which is usually an aggregate type.
+		exprField := p.B
+		expr, ok := p.B.Expression()
+		if !ok {
+			exprField = p.A
+			expr, ok = p.A.Expression()
 		}
-		if bf == nil {
+		if ok {
+			add, err := s.printExpression(expr, exprField.Var, aFieldPath, bPath)
+			if err != nil {
+				return "", errors.Wrapf(err, "cannot print expression of field %s", p.B.Var.Name())
+			}
+			computed[p.B.Var] = struct{}{}
+			out += add
 			continue
 		}
-		add, err := s.Generic.Print(af.Type(), bf.Type(), fmt.Sprintf("%s.%s", aFieldPath, af.Name()), fmt.Sprintf("%s.%s", bFieldPath, bf.Name()), levelNum)
+		add, err := s.Generic.Print(p.A.Var.Type(), p.B.Var.Type(), fmt.Sprintf("%s.%s", aFieldPath, p.A.Var.Name()), bPath, levelNum)
 		if err != nil {
 			return "", errors.Wrap(err, "cannot recursively traverse field of named type")
 		}
 		out += add
 	}
+	// Target fields with an expression don't need a source field to be paired.
+	for _, bf := range bs.Fields {
+		if _, ok := computed[bf.Var]; ok {
+			continue
+		}
+		expr, ok := bf.Expression()
+		if !ok {
+			continue
+		}
+		add, err := s.printExpression(expr, bf.Var, aFieldPath, fmt.Sprintf("%s.%s", bFieldPath, bf.Var.Name()))
+		if err != nil {
+			return "", errors.Wrapf(err, "cannot print expression of field %s", bf.Var.Name())
+		}
+		out += add
+	}
 	return out, nil
 }
+
+// printExpression returns the assignment of the expression given in the
+// marker of the field o to the target field.
+func (s *Named) printExpression(expr string, o *types.Var, aFieldPath, bFieldPath string) (string, error) {
+	e, err := QualifyExpression(expr, o, s.Imports)
+	if err != nil {
+		return "", err
+	}
+	e, err = RewriteExpression(e, aFieldPath)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("\n%s = %s", bFieldPath, e), nil
+}
+
+func (s *Named) newStruct(n *types.Named, st *types.Struct) (Struct, error) {
+	comment, err := s.commentOf(n.Obj())
+	if err != nil {
+		return Struct{}, err
+	}
+	result := Struct{
+		Named:   n,
+		Comment: comment,
+		Markers: packages.NewCommentMarkersFromText(comment, packages.CommentPrefix),
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		if f.Name() == "_" {
+			continue
+		}
+		if !s.Visibility.Includes(f, s.Imports.PackagePath) {
+			continue
+		}
+		comment, err := s.commentOf(f)
+		if err != nil {
+			return Struct{}, err
+		}
+		result.Fields = append(result.Fields, Field{
+			Var:     f,
+			Tag:     st.Tag(i),
+			Comment: comment,
+			Markers: packages.NewCommentMarkersFromText(comment, packages.CommentPrefix),
+		})
+	}
+	// The list of fields look like sorted but actually isn't. So, we need to sort
+	// it for stable output.
+	sort.SliceStable(result.Fields, func(i, j int) bool {
+		return result.Fields[i].Var.Name() < result.Fields[j].Var.Name()
+	})
+	return result, nil
+}
+
+func (s *Named) commentOf(o types.Object) (string, error) {
+	if s.Comments == nil {
+		return "", nil
+	}
+	return s.Comments.CommentOf(o)
+}
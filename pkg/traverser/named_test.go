@@ -0,0 +1,345 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traverser
+
+import (
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+	"github.com/muvaf/typewriter/pkg/test"
+)
+
+const taggedTypes = `
+package test
+
+type A struct {
+	UserID   string ` + "`json:\"userId\"`" + `
+	Id       int64
+	Group    string ` + "`json:\"group\" protobuf:\"bytes,1,opt,name=user_group\"`" + `
+	Ignored  string ` + "`json:\"-\"`" + `
+}
+
+type B struct {
+	UserIdentifier string ` + "`json:\"userId\"`" + `
+	ID             int64
+	UserGroup      string ` + "`protobuf:\"bytes,2,opt,name=user_group,proto3\"`" + `
+	Ignored2       string ` + "`json:\"-\"`" + `
+}
+`
+
+func TestNamedPrintFieldMatch(t *testing.T) {
+	s := test.ParseString(taggedTypes)
+	a := s.Lookup("A").Type().(*types.Named)
+	b := s.Lookup("B").Type().(*types.Named)
+	cases := map[string]struct {
+		fns []FieldMatchFn
+		out string
+	}{
+		"ExactName": {
+			fns: []FieldMatchFn{MatchExactName()},
+			out: "",
+		},
+		"CaseInsensitiveName": {
+			fns: []FieldMatchFn{MatchCaseInsensitiveName()},
+			out: "\nb.ID = a.Id",
+		},
+		"JSONTagName": {
+			fns: []FieldMatchFn{MatchTagName(TagKeyJSON)},
+			out: "\nb.UserIdentifier = a.UserID",
+		},
+		"Chain": {
+			fns: []FieldMatchFn{MatchExactName(), MatchTagName(TagKeyProtobuf), MatchTagName(TagKeyJSON), MatchCaseInsensitiveName()},
+			out: "\nb.UserGroup = a.Group\nb.ID = a.Id\nb.UserIdentifier = a.UserID",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			g := NewGeneric(packages.NewImports("test", "test"), WithFieldMatchFns(tc.fns...))
+			out, err := g.Print(a, b, "a", "b", 0)
+			if err != nil {
+				t.Fatalf("Print(...): unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.out, out); diff != "" {
+				t.Errorf("Print(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTagName(t *testing.T) {
+	type want struct {
+		name string
+		ok   bool
+	}
+	cases := map[string]struct {
+		tag string
+		key string
+		want
+	}{
+		"JSON": {
+			tag:  `json:"userId,omitempty"`,
+			key:  TagKeyJSON,
+			want: want{name: "userId", ok: true},
+		},
+		"YAML": {
+			tag:  `json:"userId" yaml:"user_id"`,
+			key:  TagKeyYAML,
+			want: want{name: "user_id", ok: true},
+		},
+		"Protobuf": {
+			tag:  `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3"`,
+			key:  TagKeyProtobuf,
+			want: want{name: "user_id", ok: true},
+		},
+		"Skipped": {
+			tag:  `json:"-"`,
+			key:  TagKeyJSON,
+			want: want{},
+		},
+		"NoName": {
+			tag:  `json:",omitempty"`,
+			key:  TagKeyJSON,
+			want: want{},
+		},
+		"Missing": {
+			tag:  `yaml:"userId"`,
+			key:  TagKeyJSON,
+			want: want{},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			n, ok := TagName(tc.tag, tc.key)
+			if diff := cmp.Diff(tc.want, want{name: n, ok: ok}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("TagName(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+// prefixMatcher pairs every field of the source with the target fields whose
+// names are prefixed with it to check that one-to-many matchers are honored.
+type prefixMatcher struct{}
+
+func (prefixMatcher) Match(a, b Struct) ([]FieldPair, error) {
+	var result []FieldPair
+	for _, af := range a.Fields {
+		for _, bf := range b.Fields {
+			if strings.HasPrefix(bf.Var.Name(), af.Var.Name()) {
+				result = append(result, FieldPair{A: af, B: bf})
+			}
+		}
+	}
+	return result, nil
+}
+
+func TestNamedPrintFieldMatcher(t *testing.T) {
+	s := test.ParseString(`
+package test
+
+type A struct {
+	Name string
+}
+
+type B struct {
+	NameCopy string
+	NameOriginal string
+}
+`)
+	im := packages.NewImports("test", "test")
+	cases := map[string]struct {
+		opts []Option
+	}{
+		"FieldMatcher": {
+			opts: []Option{WithFieldMatcher(prefixMatcher{})},
+		},
+		"NamedAfterFieldMatcher": {
+			// Replacing the named traverser shouldn't discard its settings.
+			opts: []Option{WithFieldMatcher(prefixMatcher{}), WithNamed(NewNamed(im))},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			g := NewGeneric(im, tc.opts...)
+			out, err := g.Print(s.Lookup("A").Type(), s.Lookup("B").Type(), "a", "b", 0)
+			if err != nil {
+				t.Fatalf("Print(...): unexpected error: %s", err)
+			}
+			if diff := cmp.Diff("\nb.NameCopy = a.Name\nb.NameOriginal = a.Name", out); diff != "" {
+				t.Errorf("Print(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNamedPrintFieldVisibility(t *testing.T) {
+	// test.ParseString type-checks the source as package with path "simple.go".
+	s := test.ParseString(`
+package test
+
+type A struct {
+	Name string
+	surname string
+}
+
+type B struct {
+	Name string
+	surname string
+}
+`)
+	cases := map[string]struct {
+		pkgPath    string
+		visibility packages.FieldVisibility
+		out        string
+	}{
+		"AutoSamePackage": {
+			pkgPath: "simple.go",
+			out:     "\nb.Name = a.Name\nb.surname = a.surname",
+		},
+		"AutoDifferentPackage": {
+			pkgPath: "github.com/org/repo/other",
+			out:     "\nb.Name = a.Name",
+		},
+		"ExportedSamePackage": {
+			pkgPath:    "simple.go",
+			visibility: packages.VisibilityExported,
+			out:        "\nb.Name = a.Name",
+		},
+		"AllDifferentPackage": {
+			pkgPath:    "github.com/org/repo/other",
+			visibility: packages.VisibilityAll,
+			out:        "\nb.Name = a.Name\nb.surname = a.surname",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			g := NewGeneric(packages.NewImports(tc.pkgPath, "test"), WithFieldVisibility(tc.visibility))
+			out, err := g.Print(s.Lookup("A").Type(), s.Lookup("B").Type(), "a", "b", 0)
+			if err != nil {
+				t.Fatalf("Print(...): unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.out, out); diff != "" {
+				t.Errorf("Print(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNamedPrintTypeParams(t *testing.T) {
+	s := test.ParseString(`
+package test
+
+type A[K comparable, V any] struct {
+	Key K
+	Value *V
+}
+
+type B[K comparable, V any] struct {
+	Key K
+	Value *V
+}
+
+type C[V any, K comparable] struct {
+	Key K
+	Value *V
+}
+`)
+	cases := map[string]struct {
+		b   types.Type
+		out string
+		err bool
+	}{
+		"SameShape": {
+			b:   s.Lookup("B").Type(),
+			out: "\nb.Key = a.Key\nb.Value = a.Value",
+		},
+		"DifferentParameterOrder": {
+			b:   s.Lookup("C").Type(),
+			err: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			g := NewGeneric(packages.NewImports("test", "test"))
+			out, err := g.Print(s.Lookup("A").Type(), tc.b, "a", "b", 0)
+			if (err != nil) != tc.err {
+				t.Fatalf("Print(...): unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.out, out); diff != "" {
+				t.Errorf("Print(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNamedPrintExpressions(t *testing.T) {
+	p := test.ParsePackage(`
+package test
+
+import str "strings"
+
+const Prefix = "user-"
+
+var _ = str.ToUpper
+
+type A struct {
+	Name string
+}
+
+type B struct {
+	// +typewriter:field:expr=str.ToUpper(a.Name)
+	Upper string
+
+	// +typewriter:field:expr=Prefix + a.Name
+	ID string
+}
+`)
+	cases := map[string]struct {
+		pkgPath string
+		out     string
+		imports map[string]string
+	}{
+		"OtherPackage": {
+			pkgPath: "github.com/org/repo/other",
+			out:     "\nb.ID = test.Prefix + a.Name\nb.Upper = strings.ToUpper(a.Name)",
+			imports: map[string]string{"simple.go": "test", "strings": "strings"},
+		},
+		"SamePackage": {
+			pkgPath: "simple.go",
+			out:     "\nb.ID = Prefix + a.Name\nb.Upper = strings.ToUpper(a.Name)",
+			imports: map[string]string{"strings": "strings"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			im := packages.NewImports(tc.pkgPath, "test")
+			g := NewGeneric(im, WithCommentCache(packages.NewCommentCache(packages.NewCache(p))))
+			out, err := g.Print(p.Types.Scope().Lookup("A").Type(), p.Types.Scope().Lookup("B").Type(), "a", "b", 0)
+			if err != nil {
+				t.Fatalf("Print(...): unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.out, out); diff != "" {
+				t.Errorf("Print(...): -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.imports, im.Imports); diff != "" {
+				t.Errorf("Print(...): imports: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -17,7 +17,6 @@ package traverser
 import (
 	"bytes"
 	"go/types"
-	"strings"
 	"text/template"
 
 	"github.com/muvaf/typewriter/pkg/packages"
@@ -73,11 +72,11 @@ func (p *Pointer) Print(a, b *types.Pointer, aFieldPath, bFieldPath string, leve
 	}
 	i := PointerTmplInput{
 		AFieldPath:      aFieldPath,
-		TypeA:           p.Imports.UseType(a.String()),
-		NonPointerTypeA: strings.TrimPrefix(p.Imports.UseType(a.String()), "*"),
+		TypeA:           p.Imports.TypeString(a),
+		NonPointerTypeA: p.Imports.TypeString(a.Elem()),
 		BFieldPath:      bFieldPath,
-		TypeB:           p.Imports.UseType(b.String()),
-		NonPointerTypeB: strings.TrimPrefix(p.Imports.UseType(b.String()), "*"),
+		TypeB:           p.Imports.TypeString(b),
+		NonPointerTypeB: p.Imports.TypeString(b.Elem()),
 		Statements:      statements,
 	}
 	t, err := template.New("func").Parse(p.Template)
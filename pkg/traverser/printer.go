@@ -29,7 +29,7 @@ import (
 const DirectProducerTmpl = `
 // {{ .FunctionName }} returns a new {{ .BTypeName }} with the information from
 // given {{ .ATypeName }}.
-func {{ .FunctionName }}(a {{ .ATypeName }}) {{ .BTypeName }} {
+func {{ .FunctionName }}{{ .TypeParams }}(a {{ .ATypeName }}) {{ .BTypeName }} {
   b := {{ .BTypeNewStatement }}
 {{ .Statements }}
   return b
@@ -41,6 +41,18 @@ func WithTemplate(t string) PrinterOption {
 	}
 }
 
+// WithDefaultValues makes the printer assign the literals given via default
+// markers on the fields of the target type and its nested structs before the
+// traversed statements.
+// The comments are fetched from the package of each field, so the fields of
+// types generated by types.Merger get the markers of the fields they originate
+// from.
+func WithDefaultValues(cc *packages.CommentCache) PrinterOption {
+	return func(p *Printer) {
+		p.Comments = cc
+	}
+}
+
 type PrinterOption func(p *Printer)
 
 func NewPrinter(im *packages.Imports, tr GenericTraverser, opts ...PrinterOption) *Printer {
@@ -52,6 +64,13 @@ func NewPrinter(im *packages.Imports, tr GenericTraverser, opts ...PrinterOption
 	for _, o := range opts {
 		o(f)
 	}
+	if d, ok := tr.(DefaultValuesSetter); ok && f.Comments != nil {
+		d.SetDefaultValues(f.Comments)
+	}
+	// The parameters and the loop variables of the generated functions would
+	// shadow the packages imported with their names.
+	im.Reserve("a", "b")
+	im.ReserveNumbered(sliceIndexPrefix, mapKeyPrefix, mapValuePrefix)
 	return f
 }
 
@@ -59,6 +78,10 @@ type Printer struct {
 	Imports   *packages.Imports
 	Traverser GenericTraverser
 	Template  string
+
+	// Comments is used to fetch the default value markers of the target type.
+	// Default values are not assigned if it's nil.
+	Comments *packages.CommentCache
 }
 
 func (p *Printer) Print(name string, a, b types.Type, extraInput map[string]interface{}) (string, error) {
@@ -84,13 +107,34 @@ func (p *Printer) Print(name string, a, b types.Type, extraInput map[string]inte
 	default:
 		bn = b.(*types.Named)
 	}
-	aTypeDec := p.Imports.UseType(an.String())
+	aTypeDec := p.Imports.TypeString(an)
+	bTypeDec := p.Imports.TypeString(bn)
+	// Uninstantiated generic types result in a generic function whose type
+	// parameters are shared by both types, i.e. List[T] to ListV2[T].
+	typeParams := ""
+	if isGeneric(an) || isGeneric(bn) {
+		if !isGeneric(an) || !isGeneric(bn) || an.TypeParams().Len() != bn.TypeParams().Len() {
+			return "", errors.Errorf("generic types %s and %s need to have the same number of type parameters", an.Obj().Name(), bn.Obj().Name())
+		}
+		var args string
+		typeParams, args = packages.TypeParamsString(an.TypeParams(), p.Imports.TypeString)
+		aTypeDec = p.Imports.ObjectString(an.Obj()) + args
+		bTypeDec = p.Imports.ObjectString(bn.Obj()) + args
+	}
 	aTypeName := fmt.Sprintf("%s%s", aNamePrefix, aTypeDec)
 	aNewStatement := fmt.Sprintf("%s{}", aTypeName)
 	if aNamePrefix == "*" {
 		aNewStatement = fmt.Sprintf("&%s", aNewStatement)
 	}
-	bTypeDec := p.Imports.UseType(bn.String())
+	// The traversers that don't assign defaults on their own get only the
+	// defaults of the top level type.
+	if _, ok := p.Traverser.(DefaultValuesSetter); !ok && p.Comments != nil {
+		defaults, err := PrintDefaults(p.Comments, p.Imports, bn, "b")
+		if err != nil {
+			return "", errors.Wrap(err, "cannot print default values")
+		}
+		content = defaults + content
+	}
 	bTypeName := fmt.Sprintf("%s%s", bNamePrefix, bTypeDec)
 	bNewStatement := fmt.Sprintf("%s{}", bTypeName)
 	if bNamePrefix == "*" {
@@ -98,6 +142,7 @@ func (p *Printer) Print(name string, a, b types.Type, extraInput map[string]inte
 	}
 	ts := map[string]interface{}{
 		"FunctionName":      name,
+		"TypeParams":        typeParams,
 		"ATypeName":         aTypeName,
 		"ATypeNewStatement": aNewStatement,
 		"BTypeName":         bTypeName,
@@ -112,6 +157,14 @@ func (p *Printer) Print(name string, a, b types.Type, extraInput map[string]inte
 		return "", errors.Wrap(err, "cannot parse template")
 	}
 	result := &bytes.Buffer{}
-	err = t.Execute(result, ts)
-	return strings.ReplaceAll(result.String(), "\n\n", "\n"), errors.Wrap(err, "cannot execute template")
+	if err := t.Execute(result, ts); err != nil {
+		return "", errors.Wrap(err, "cannot execute template")
+	}
+	return strings.ReplaceAll(result.String(), "\n\n", "\n"), nil
+}
+
+// isGeneric returns true if the type is a generic type that is not
+// instantiated.
+func isGeneric(n *types.Named) bool {
+	return n.TypeParams().Len() != 0 && n.TypeArgs().Len() == 0
 }
@@ -0,0 +1,226 @@
+// Copyright 2022 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traverser
+
+import (
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+	"github.com/muvaf/typewriter/pkg/test"
+)
+
+func TestPrinterDefaults(t *testing.T) {
+	p := test.ParsePackage(`
+package test
+
+type Status string
+
+const StatusActive Status = "active"
+
+type Spec struct {
+	Name string
+
+	// +typewriter:field:default=3
+	Replicas int
+}
+
+type Item struct {
+	// +typewriter:field:default="item"
+	Kind string
+
+	Name string
+}
+
+type SourceItem struct {
+	Name string
+}
+
+type A struct {
+	Name  string
+	Owner *Spec
+	Items []SourceItem
+}
+
+type B struct {
+	Name string
+
+	// +typewriter:field:default=StatusActive
+	Status Status
+
+	Spec   Spec
+	Owner  *Spec
+	Backup *Spec
+	Items  []Item
+}
+`)
+	c := packages.NewCache(p)
+	im := packages.NewImports("example.com/app", "app")
+	fn := NewPrinter(im, NewGeneric(im),
+		WithDefaultValues(packages.NewCommentCache(c)),
+	)
+	out, err := fn.Print("GenerateB", p.Types.Scope().Lookup("A").Type(), p.Types.Scope().Lookup("B").Type(), nil)
+	if err != nil {
+		t.Fatalf("Print(...): unexpected error: %s", err)
+	}
+	want := `
+// GenerateB returns a new test.B with the information from
+// given test.A.
+func GenerateB(a test.A) test.B {
+  b := test.B{}
+b.Status = test.StatusActive
+b.Spec.Replicas = 3
+if len(a.Items) != 0 {
+  b.Items = make([]test.Item, len(a.Items))
+  for v0 := range a.Items {
+b.Items[v0].Kind = "item"
+b.Items[v0].Name = a.Items[v0].Name
+  }
+}
+b.Name = a.Name
+if a.Owner != nil {
+  b.Owner = new(test.Spec)
+b.Owner.Replicas = 3
+b.Owner.Name = a.Owner.Name
+b.Owner.Replicas = a.Owner.Replicas
+}
+  return b
+}`
+	if diff := cmp.Diff(want, out); diff != "" {
+		t.Errorf("Print(...): -want, +got:\n%s", diff)
+	}
+	if err := Validate(c, im, out); err != nil {
+		t.Errorf("Validate(...): unexpected error: %s", err)
+	}
+}
+
+func TestPrinterMapDefaults(t *testing.T) {
+	p := test.ParsePackage(`
+package test
+
+type Holder struct {
+	// +typewriter:field:default=3
+	Count int
+
+	Name string
+}
+
+type A struct {
+	Items map[string]Holder
+}
+
+type B struct {
+	Items map[string]Holder
+}
+`)
+	c := packages.NewCache(p)
+	im := packages.NewImports("example.com/app", "app")
+	fn := NewPrinter(im, NewGeneric(im), WithDefaultValues(packages.NewCommentCache(c)))
+	out, err := fn.Print("GenerateB", p.Types.Scope().Lookup("A").Type(), p.Types.Scope().Lookup("B").Type(), nil)
+	if err != nil {
+		t.Fatalf("Print(...): unexpected error: %s", err)
+	}
+	// Fields of map elements cannot be assigned, so the elements are filled in
+	// a variable first.
+	want := `
+// GenerateB returns a new test.B with the information from
+// given test.A.
+func GenerateB(a test.A) test.B {
+  b := test.B{}
+if len(a.Items) != 0 {
+  b.Items = make(map[string]test.Holder, len(a.Items))
+  for k0 := range a.Items {
+  e0 := b.Items[k0]
+e0.Count = 3
+e0.Count = a.Items[k0].Count
+e0.Name = a.Items[k0].Name
+  b.Items[k0] = e0
+  }
+}
+  return b
+}`
+	if diff := cmp.Diff(want, out); diff != "" {
+		t.Errorf("Print(...): -want, +got:\n%s", diff)
+	}
+	if err := Validate(c, im, out); err != nil {
+		t.Errorf("Validate(...): unexpected error: %s", err)
+	}
+}
+
+func TestPrinterLoopVariables(t *testing.T) {
+	v1 := types.NewPackage("k8s.io/api/core/v1", "v1")
+	app := types.NewPackage("example.com/app", "app")
+	foo := types.NewNamed(types.NewTypeName(token.NoPos, v1, "Foo", nil), types.NewStruct([]*types.Var{
+		types.NewField(token.NoPos, v1, "Name", types.Typ[types.String], false),
+	}, nil), nil)
+	item := types.NewNamed(types.NewTypeName(token.NoPos, app, "Item", nil), types.NewStruct([]*types.Var{
+		types.NewField(token.NoPos, app, "Sub", types.NewSlice(types.NewPointer(foo)), false),
+	}, nil), nil)
+	a := types.NewNamed(types.NewTypeName(token.NoPos, app, "A", nil), types.NewStruct([]*types.Var{
+		types.NewField(token.NoPos, app, "Items", types.NewSlice(item), false),
+	}, nil), nil)
+	im := packages.NewImports("example.com/other", "other")
+	out, err := NewPrinter(im, NewGeneric(im)).Print("CopyA", a, a, nil)
+	if err != nil {
+		t.Fatalf("Print(...): unexpected error: %s", err)
+	}
+	want := `
+// CopyA returns a new app.A with the information from
+// given app.A.
+func CopyA(a app.A) app.A {
+  b := app.A{}
+if len(a.Items) != 0 {
+  b.Items = make([]app.Item, len(a.Items))
+  for v0 := range a.Items {
+if len(a.Items[v0].Sub) != 0 {
+  b.Items[v0].Sub = make([]*corev1.Foo, len(a.Items[v0].Sub))
+  for v1 := range a.Items[v0].Sub {
+if a.Items[v0].Sub[v1] != nil {
+  b.Items[v0].Sub[v1] = new(corev1.Foo)
+b.Items[v0].Sub[v1].Name = a.Items[v0].Sub[v1].Name
+}
+  }
+}
+  }
+}
+  return b
+}`
+	if diff := cmp.Diff(want, out); diff != "" {
+		t.Errorf("Print(...): -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]string{"example.com/app": "app", "k8s.io/api/core/v1": "corev1"}, im.Imports); diff != "" {
+		t.Errorf("Print(...): imports: -want, +got:\n%s", diff)
+	}
+}
+
+func TestNewPrinterReservedIdentifiers(t *testing.T) {
+	im := packages.NewImports("example.com/other", "other")
+	NewPrinter(im, NewGeneric(im))
+	for _, name := range []string{"a", "v1", "k2", "e3"} {
+		im.Qualifier()(types.NewPackage("example.com/app/"+name, name))
+	}
+	want := map[string]string{
+		"example.com/app/a":  "appa",
+		"example.com/app/v1": "appv1",
+		"example.com/app/k2": "appk2",
+		"example.com/app/e3": "appe3",
+	}
+	if diff := cmp.Diff(want, im.Imports); diff != "" {
+		t.Errorf("NewPrinter(...): imports: -want, +got:\n%s", diff)
+	}
+}
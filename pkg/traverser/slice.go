@@ -33,6 +33,10 @@ if len({{ .AFieldPath }}) != 0 {
   }
 }`
 
+// sliceIndexPrefix is the prefix of the index variables of the loops over
+// slices, which is followed by the nesting level.
+const sliceIndexPrefix = "v"
+
 type SliceTmplInput struct {
 	AFieldPath string
 	TypeA      string
@@ -64,16 +68,16 @@ func (s *Slice) SetGenericTraverser(p GenericTraverser) {
 }
 
 func (s *Slice) Print(a, b *types.Slice, aFieldPath, bFieldPath string, levelNum int) (string, error) {
-	index := fmt.Sprintf("v%d", levelNum)
+	index := fmt.Sprintf("%s%d", sliceIndexPrefix, levelNum)
 	statements, err := s.Generic.Print(a.Elem(), b.Elem(), fmt.Sprintf("%s[%s]", aFieldPath, index), fmt.Sprintf("%s[%s]", bFieldPath, index), levelNum+1)
 	if err != nil {
 		return "", errors.Wrap(err, "cannot recursively traverse element type of slice")
 	}
 	i := SliceTmplInput{
 		AFieldPath: aFieldPath,
-		TypeA:      s.Imports.UseType(a.String()),
+		TypeA:      s.Imports.TypeString(a),
 		BFieldPath: bFieldPath,
-		TypeB:      s.Imports.UseType(b.String()),
+		TypeB:      s.Imports.TypeString(b),
 		Index:      index,
 		Statements: statements,
 	}
@@ -0,0 +1,119 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traverser
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+)
+
+const validationFileName = "zz_typewriter_validation.go"
+
+// Validate type-checks the generated functions as if they were written to the
+// package of given imports, together with the existing files of that package
+// if it's already there. All functions that will end up in the same file are
+// meant to be given at once so that the package is type-checked only once.
+// Only the errors in the generated functions are reported and soft errors like
+// unused imports are ignored since the import list belongs to the whole file.
+func Validate(cache *packages.Cache, im *packages.Imports, fns string) error {
+	paths := make([]string, 0, len(im.Imports))
+	for p := range im.Imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	src := &strings.Builder{}
+	fmt.Fprintf(src, "package %s\n\nimport (\n", im.PackageName)
+	for _, p := range paths {
+		fmt.Fprintf(src, "\t%s %q\n", im.Imports[p], p)
+	}
+	fmt.Fprintf(src, ")\n%s\n", fns)
+
+	// The package may not exist yet or may not even compile because of the
+	// stale generated code, in which case we check the functions on their own.
+	fset := token.NewFileSet()
+	var existing []*ast.File
+	if pkg, err := cache.GetPackage(im.PackagePath); err == nil {
+		fset = pkg.Fset
+		existing = pkg.Syntax
+	}
+	generated, err := parser.ParseFile(fset, validationFileName, src.String(), 0)
+	if err != nil {
+		return errors.Wrap(err, "cannot parse generated functions")
+	}
+	names := map[string]struct{}{}
+	for _, d := range generated.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Recv == nil {
+			names[fd.Name.Name] = struct{}{}
+		}
+	}
+	files := []*ast.File{generated}
+	for _, f := range existing {
+		files = append(files, withoutFuncs(f, names))
+	}
+	var errs []string
+	cfg := &types.Config{
+		Importer: cache.Importer(),
+		Error: func(err error) {
+			te, ok := err.(types.Error)
+			if !ok || te.Soft || te.Fset.Position(te.Pos).Filename != validationFileName {
+				return
+			}
+			errs = append(errs, fmt.Sprintf("%s: %s", enclosingFunc(generated, te.Pos), te.Msg))
+		},
+	}
+	// Errors are collected by the handler above.
+	_, _ = cfg.Check(im.PackagePath, fset, files, nil)
+	if len(errs) != 0 {
+		return errors.Errorf("generated functions do not type-check: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// withoutFuncs returns a shallow copy of the file without the declarations of
+// the functions with given names so that the previously generated versions of
+// the functions don't clash with the new ones.
+func withoutFuncs(f *ast.File, names map[string]struct{}) *ast.File {
+	result := *f
+	result.Decls = nil
+	for _, d := range f.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Recv == nil {
+			if _, ok := names[fd.Name.Name]; ok {
+				continue
+			}
+		}
+		result.Decls = append(result.Decls, d)
+	}
+	return &result
+}
+
+// enclosingFunc returns the name of the function declaration in the file that
+// contains given position.
+func enclosingFunc(f *ast.File, pos token.Pos) string {
+	for _, d := range f.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Pos() <= pos && pos < fd.End() {
+			return fd.Name.Name
+		}
+	}
+	return validationFileName
+}
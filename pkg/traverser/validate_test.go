@@ -0,0 +1,120 @@
+// Copyright 2022 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traverser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+	"github.com/muvaf/typewriter/pkg/test"
+)
+
+func TestValidate(t *testing.T) {
+	p := test.ParsePackage(`
+package test
+
+type A struct {
+	Name string
+}
+
+type B struct {
+	Upper string
+}
+
+func Convert(a A) B {
+	return B{}
+}
+`)
+	cases := map[string]struct {
+		imports map[string]string
+		fns     string
+		err     string
+	}{
+		"Valid": {
+			imports: map[string]string{"strings": "strings"},
+			fns: `func Convert(a A) B {
+	b := B{}
+	b.Upper = strings.ToUpper(a.Name)
+	return b
+}`,
+		},
+		"MissingImport": {
+			fns: `func Convert(a A) B {
+	b := B{}
+	b.Upper = strings.ToUpper(a.Name)
+	return b
+}`,
+			err: "undefined: strings",
+		},
+		"TypeMismatch": {
+			fns: `func Convert(a A) B {
+	b := B{}
+	b.Upper = len(a.Name)
+	return b
+}`,
+			err: "Convert: cannot use len(a.Name)",
+		},
+		"MultipleFunctions": {
+			fns: `func Convert(a A) B {
+	b := B{}
+	b.Upper = a.Name
+	return b
+}
+
+func ConvertAll(as []A) []B {
+	result := make([]B, len(as))
+	for i := range as {
+		result[i] = Convert(as[i])
+	}
+	return result
+}`,
+		},
+		"ErrorInSecondFunction": {
+			fns: `func Convert(a A) B {
+	return B{}
+}
+
+func ConvertPtr(a *A) *B {
+	b := Convert(a)
+	return &b
+}`,
+			err: "ConvertPtr: cannot use a",
+		},
+		"UnusedImport": {
+			imports: map[string]string{"strings": "strings", "fmt": "fmt"},
+			fns: `func Convert(a A) B {
+	b := B{}
+	b.Upper = strings.ToUpper(a.Name)
+	return b
+}`,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			im := packages.NewImports("simple.go", "test")
+			for path, alias := range tc.imports {
+				im.Imports[path] = alias
+			}
+			err := Validate(packages.NewCache(p), im, tc.fns)
+			switch {
+			case tc.err == "" && err != nil:
+				t.Errorf("Validate(...): unexpected error: %s", err)
+			case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+				t.Errorf("Validate(...): expected error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Constants holds the constants of the named types indexed by the full paths
+// of the types in <package path>.<type name> format.
+type Constants map[string][]ConstantGroup
+
+// ConstantGroup is a group of constants declared in the same const block.
+type ConstantGroup struct {
+	Comment string
+	Specs   []ConstantSpec
+}
+
+// ConstantSpec is a line of a const block, i.e. `A, B Status = iota, 2`.
+// Type and Values are empty for the lines that repeat the previous one.
+type ConstantSpec struct {
+	Comment     string
+	LineComment string
+	Names       []string
+	Type        string
+	Values      []string
+}
+
+// copyConstants adds the constants of the source type to the Constants of
+// the flattener. The const blocks that have only the constants of the source
+// type and refer only to iota, the type and predeclared identifiers are copied
+// as they are. Otherwise, the constants are copied with their values.
+func (f *Flattener) copyConstants(src, local *types.Named, taken func(string) bool, take func(string)) error {
+	p, err := f.ConstantCache.GetPackage(src.Obj().Pkg().Path())
+	if err != nil {
+		return errors.Wrapf(err, "cannot get package %s", src.Obj().Pkg().Path())
+	}
+	scope := src.Obj().Pkg().Scope()
+	var groups []ConstantGroup
+	for _, file := range p.Syntax {
+		for _, decl := range file.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.CONST {
+				continue
+			}
+			var consts []*types.Const
+			onlySource := true
+			for _, spec := range gd.Specs {
+				for _, n := range spec.(*ast.ValueSpec).Names {
+					c, ok := scope.Lookup(n.Name).(*types.Const)
+					if !ok {
+						continue
+					}
+					if types.Identical(c.Type(), src) {
+						consts = append(consts, c)
+					} else {
+						onlySource = false
+					}
+				}
+			}
+			if len(consts) == 0 {
+				continue
+			}
+			names := map[string]string{}
+			for _, c := range consts {
+				name := c.Name()
+				if taken(name) {
+					name = f.RenameFn(name, types.NewTypeName(c.Pos(), c.Pkg(), c.Name(), nil), taken)
+				}
+				take(name)
+				names[c.Name()] = name
+				f.constNames[c] = name
+			}
+			g := ConstantGroup{Comment: commentText(gd.Doc)}
+			if onlySource && selfContained(gd, src.Obj().Name()) {
+				g.Specs = sourceSpecs(gd, names, src.Obj().Name(), local.Obj().Name())
+			} else {
+				g.Specs = valueSpecs(consts, names, local.Obj().Name())
+			}
+			groups = append(groups, g)
+		}
+	}
+	if len(groups) != 0 {
+		f.Constants[QualifiedTypePath(local.Obj())] = groups
+	}
+	return nil
+}
+
+// selfContained returns true if the expressions in the const block refer only
+// to iota, the type with given name and predeclared identifiers.
+func selfContained(gd *ast.GenDecl, typeName string) bool {
+	result := true
+	for _, spec := range gd.Specs {
+		vs := spec.(*ast.ValueSpec)
+		exprs := append([]ast.Expr{vs.Type}, vs.Values...)
+		for _, e := range exprs {
+			if e == nil {
+				continue
+			}
+			ast.Inspect(e, func(n ast.Node) bool {
+				id, ok := n.(*ast.Ident)
+				if !ok {
+					return true
+				}
+				if id.Name != "iota" && id.Name != typeName && types.Universe.Lookup(id.Name) == nil {
+					result = false
+				}
+				return true
+			})
+		}
+	}
+	return result
+}
+
+// sourceSpecs returns the specs of the const block as they are written with
+// the names of the type and the constants replaced with the local ones.
+func sourceSpecs(gd *ast.GenDecl, names map[string]string, typeName, localName string) []ConstantSpec {
+	result := make([]ConstantSpec, len(gd.Specs))
+	for i, spec := range gd.Specs {
+		vs := spec.(*ast.ValueSpec)
+		cs := ConstantSpec{
+			Comment:     commentText(vs.Doc),
+			LineComment: commentText(vs.Comment),
+		}
+		for _, n := range vs.Names {
+			name := n.Name
+			if local, ok := names[name]; ok {
+				name = local
+			}
+			cs.Names = append(cs.Names, name)
+		}
+		if vs.Type != nil {
+			cs.Type = renameIdent(types.ExprString(vs.Type), typeName, localName)
+		}
+		for _, v := range vs.Values {
+			cs.Values = append(cs.Values, renameIdent(types.ExprString(v), typeName, localName))
+		}
+		result[i] = cs
+	}
+	return result
+}
+
+// valueSpecs returns a spec for every constant with its value.
+func valueSpecs(consts []*types.Const, names map[string]string, localName string) []ConstantSpec {
+	result := make([]ConstantSpec, len(consts))
+	for i, c := range consts {
+		result[i] = ConstantSpec{
+			Names:  []string{names[c.Name()]},
+			Type:   localName,
+			Values: []string{constantValue(c.Val())},
+		}
+	}
+	return result
+}
+
+// constantValue returns the Go literal of the value.
+func constantValue(v constant.Value) string {
+	s := v.ExactString()
+	// Exact form of the fractions, like 1/3, would be an integer division.
+	if v.Kind() == constant.Float && strings.Contains(s, "/") {
+		return v.String()
+	}
+	return s
+}
+
+// renameIdent replaces the identifiers with given name in the expression.
+func renameIdent(expr, from, to string) string {
+	if from == to {
+		return expr
+	}
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(expr))
+	var s scanner.Scanner
+	s.Init(file, []byte(expr), nil, 0)
+	var b strings.Builder
+	last := 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.IDENT && lit == from {
+			offset := file.Offset(pos)
+			b.WriteString(expr[last:offset])
+			b.WriteString(to)
+			last = offset + len(lit)
+		}
+	}
+	b.WriteString(expr[last:])
+	return b.String()
+}
+
+// commentText returns the comment in the form that Printer expects.
+func commentText(cg *ast.CommentGroup) string {
+	if cg == nil {
+		return ""
+	}
+	return commentLines(strings.TrimSpace(cg.Text()))
+}
+
+// printConstants returns the const blocks of the type.
+func (tp *Printer) printConstants(n *types.Named) string {
+	out := ""
+	for _, g := range tp.Constants[QualifiedTypePath(n.Obj())] {
+		out += "\n\n"
+		if g.Comment != "" {
+			out += g.Comment + "\n"
+		}
+		out += "const ("
+		for _, s := range g.Specs {
+			if s.Comment != "" {
+				out += "\n" + s.Comment
+			}
+			line := strings.Join(s.Names, ", ")
+			if s.Type != "" {
+				line += " " + s.Type
+			}
+			if len(s.Values) != 0 {
+				line += " = " + strings.Join(s.Values, ", ")
+			}
+			if s.LineComment != "" {
+				line += " " + s.LineComment
+			}
+			out += fmt.Sprintf("\n%s", line)
+		}
+		out += "\n)"
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/pkg/errors"
+)
+
+// DeclaredTypes holds the named types that the named types are declared with,
+// like Base in `type Derived Base`, indexed by the full paths of the declared
+// types in <package path>.<type name> format.
+type DeclaredTypes map[string]types.Type
+
+// declaredType returns the named type that the given remote type is declared
+// with, or nil if it's declared with a type literal. The underlying type of a
+// named type doesn't have this information, so it's read from the declaration.
+// Struct types are skipped since their fields are printed instead.
+func (f *Flattener) declaredType(n *types.Named) (*types.Named, error) {
+	if n.Obj().Pkg() == nil || f.isLocal(n) || n.TypeParams().Len() != 0 || n.TypeArgs().Len() != 0 {
+		return nil, nil
+	}
+	if _, ok := n.Underlying().(*types.Struct); ok {
+		return nil, nil
+	}
+	p, err := f.DeclarationCache.GetPackage(n.Obj().Pkg().Path())
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get package %s", n.Obj().Pkg().Path())
+	}
+	if p.Types.Scope().Lookup(n.Obj().Name()) != n.Obj() {
+		return nil, errors.Errorf("type %s is not loaded from the declaration cache", QualifiedTypePath(n.Obj()))
+	}
+	spec := typeSpec(p.Syntax, n.Obj().Name())
+	if spec == nil || spec.Assign.IsValid() {
+		return nil, nil
+	}
+	// The expression is evaluated in the scope of its file so that the imports
+	// of the file are resolved.
+	tv, err := types.Eval(p.Fset, p.Types, spec.Type.Pos(), types.ExprString(spec.Type))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot evaluate declaration of type %s", n.Obj().Name())
+	}
+	d, _ := tv.Type.(*types.Named)
+	return d, nil
+}
+
+// typeSpec returns the package-level declaration of the type with given name.
+func typeSpec(files []*ast.File, name string) *ast.TypeSpec {
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				if ts := spec.(*ast.TypeSpec); ts.Name.Name == name {
+					return ts
+				}
+			}
+		}
+	}
+	return nil
+}
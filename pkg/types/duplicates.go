@@ -0,0 +1,161 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+)
+
+// DuplicateStrategy decides what Printer does when a type with the same name
+// but a different structure exists in the target scope.
+type DuplicateStrategy int
+
+const (
+	// DuplicateError returns an error with the differences.
+	DuplicateError DuplicateStrategy = iota
+	// DuplicateRename prints the type with a name that is not taken and
+	// refers to it with that name.
+	DuplicateRename
+	// DuplicateReport keeps the existing type and records the differences in
+	// the Duplicates of the printer.
+	DuplicateReport
+)
+
+// Duplicate is a type whose name is taken in the target scope by a type with a
+// different structure.
+type Duplicate struct {
+	Name string
+	// Diff has the lines of the existing type prefixed with "-" and the ones of
+	// the printed type prefixed with "+".
+	Diff []string
+}
+
+func (d Duplicate) String() string {
+	return fmt.Sprintf("type %s differs from the existing one:\n%s", d.Name, strings.Join(d.Diff, "\n"))
+}
+
+// structureDiff returns the differences between the structures of the existing
+// object and the type, or nil if they're the same. The fields of structs are
+// compared one by one while other types are compared as a whole.
+func structureDiff(existing types.Object, n *types.Named) []string {
+	if existing == n.Obj() {
+		return nil
+	}
+	if _, ok := existing.(*types.TypeName); !ok {
+		return []string{fmt.Sprintf("-%s", existing), fmt.Sprintf("+type %s", n.Obj().Name())}
+	}
+	var diff []string
+	if a, b := typeParamsString(existing.Type()), typeParamsString(n); a != b {
+		diff = append(diff, "-"+a, "+"+b)
+	}
+	es, eok := existing.Type().Underlying().(*types.Struct)
+	ns, nok := n.Underlying().(*types.Struct)
+	if !eok || !nok {
+		if a, b := structureString(existing.Type().Underlying()), structureString(n.Underlying()); a != b {
+			diff = append(diff, "-"+a, "+"+b)
+		}
+		return diff
+	}
+	ef, nf := fieldLines(es), fieldLines(ns)
+	for _, l := range ef {
+		if !contains(nf, l) {
+			diff = append(diff, "-"+l)
+		}
+	}
+	for _, l := range nf {
+		if !contains(ef, l) {
+			diff = append(diff, "+"+l)
+		}
+	}
+	return diff
+}
+
+// fieldLines returns the fields of the struct as they'd be declared with
+// their types fully qualified.
+func fieldLines(s *types.Struct) []string {
+	result := make([]string, s.NumFields())
+	for i := 0; i < s.NumFields(); i++ {
+		l := fmt.Sprintf("%s %s", s.Field(i).Name(), structureString(s.Field(i).Type()))
+		if s.Field(i).Embedded() {
+			l = structureString(s.Field(i).Type())
+		}
+		if s.Tag(i) != "" {
+			l += fmt.Sprintf(" `%s`", s.Tag(i))
+		}
+		result[i] = l
+	}
+	return result
+}
+
+// structureString returns the type with the named types qualified with their
+// full package paths so that the copies and the originals in the same package
+// are written the same.
+func structureString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		return p.Path()
+	})
+}
+
+func typeParamsString(t types.Type) string {
+	n, ok := t.(*types.Named)
+	if !ok || n.TypeParams().Len() == 0 {
+		return ""
+	}
+	params := make([]string, n.TypeParams().Len())
+	for i := range params {
+		params[i] = fmt.Sprintf("%s %s", n.TypeParams().At(i).Obj().Name(), structureString(n.TypeParams().At(i).Constraint()))
+	}
+	return fmt.Sprintf("[%s]", strings.Join(params, ", "))
+}
+
+// withRenames returns the type with the references to the renamed types
+// replaced with the renamed ones.
+func (tp *Printer) withRenames(t types.Type) types.Type {
+	if len(tp.renamed) == 0 {
+		return t
+	}
+	switch u := t.(type) {
+	case *types.Named:
+		if u.TypeArgs().Len() != 0 {
+			origin, ok := tp.renamed[u.Origin().Obj()]
+			if !ok {
+				origin = u.Origin()
+			}
+			targs := make([]types.Type, u.TypeArgs().Len())
+			for i := range targs {
+				targs[i] = tp.withRenames(u.TypeArgs().At(i))
+			}
+			return instantiate(origin, targs)
+		}
+		if r, ok := tp.renamed[u.Obj()]; ok {
+			return r
+		}
+		return u
+	case *types.Pointer:
+		return types.NewPointer(tp.withRenames(u.Elem()))
+	case *types.Slice:
+		return types.NewSlice(tp.withRenames(u.Elem()))
+	case *types.Array:
+		return types.NewArray(tp.withRenames(u.Elem()), u.Len())
+	case *types.Map:
+		return types.NewMap(tp.withRenames(u.Key()), tp.withRenames(u.Elem()))
+	case *types.Chan:
+		return types.NewChan(u.Dir(), tp.withRenames(u.Elem()))
+	default:
+		return t
+	}
+}
@@ -14,7 +14,17 @@
 
 package types
 
-import "go/types"
+import (
+	"encoding/json"
+	"go/types"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+)
 
 type TypeFilterChain []TypeFilter
 
@@ -36,56 +46,300 @@ func (tc NopTypeFilter) Filter(t *types.Named) *types.Named {
 
 type FieldFilterChain []FieldFilter
 
-func (tc FieldFilterChain) Filter(field *types.Var, tag string) (*types.Var, string) {
+func (tc FieldFilterChain) Filter(owner FieldOwner, field *types.Var, tag string) (*types.Var, string) {
 	for _, tf := range tc {
 		if field == nil {
 			return nil, ""
 		}
-		field, tag = tf.Filter(field, tag)
+		field, tag = tf.Filter(owner, field, tag)
 	}
 	return field, tag
 }
 
+// UsesFieldPaths returns true if any of the filters uses the paths of the
+// nested fields.
+func (tc FieldFilterChain) UsesFieldPaths() bool {
+	for _, tf := range tc {
+		if fp, ok := tf.(FieldPathFilter); ok && fp.UsesFieldPaths() {
+			return true
+		}
+	}
+	return false
+}
+
 type NopFieldFilter struct{}
 
-func (tc NopFieldFilter) Filter(field *types.Var, tag string) (*types.Var, string) {
+func (tc NopFieldFilter) Filter(_ FieldOwner, field *types.Var, tag string) (*types.Var, string) {
 	return field, tag
 }
 
-func NewIgnoreTypeFilter(list []string) *IgnoreTypeFilter {
-	ig := &IgnoreTypeFilter{}
-	for _, t := range list {
-		ig.ignore[t] = struct{}{}
+// IgnoreRules are the glob patterns of the types and fields that should be
+// ignored. Type patterns are matched against the full path of the type in
+// <package path>.<type name> format and field patterns against the full path
+// of the field in <package path>.<type name>:<field name> format, see
+// QualifiedTypePath and QualifiedFieldPath.
+//
+// In patterns, "*" matches any sequence of characters except "/" and "**"
+// matches any sequence of characters. A type pattern without "." or a field
+// pattern without ":" and "." is matched against the name only. A field
+// pattern without ":" but with "." starts with a type name in any package,
+// i.e. Spec.Secret is the same as **.Spec:Secret.
+//
+// Field patterns can also be paths of nested fields, i.e.
+// <package path>.<type name>:<field name>.<field name>..., which are matched
+// against the paths the fields are reached through from the other types, see
+// FieldOwner. In the field names, "*" matches a single field name. For
+// example:
+//
+//	github.com/org/sdk/*.Internal*  types starting with Internal in packages directly under sdk.
+//	**.Spec:Secret                  Secret field of all types named Spec.
+//	Secret                          all fields named Secret.
+//	Spec.*.Secret                   Secret field of the types of all fields of Spec.
+//
+// Since the nested types are copied once, a field dropped because of one of
+// its paths is dropped wherever its type is used.
+type IgnoreRules struct {
+	Types  []string `json:"types,omitempty"`
+	Fields []string `json:"fields,omitempty"`
+}
+
+// LoadIgnoreRules reads the rules from the JSON file in given path, i.e.
+// {"types": ["**.Internal*"], "fields": ["**.Spec:Secret"]}
+func LoadIgnoreRules(path string) (IgnoreRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return IgnoreRules{}, errors.Wrapf(err, "cannot read ignore rules file %s", path)
+	}
+	r := IgnoreRules{}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return IgnoreRules{}, errors.Wrapf(err, "cannot parse ignore rules file %s", path)
+	}
+	return r, errors.Wrapf(r.Validate(), "invalid ignore rules in file %s", path)
+}
+
+// Validate returns error if any of the patterns is not in a supported format.
+func (r IgnoreRules) Validate() error {
+	if _, err := compileTypePatterns(r.Types); err != nil {
+		return errors.Wrap(err, "invalid type pattern")
+	}
+	_, err := compileFieldPatterns(r.Fields)
+	return errors.Wrap(err, "invalid field pattern")
+}
+
+// IgnoreRulesFromMarkers returns the rules for the types and fields in the
+// package in given path that are marked with +typewriter:types:ignore and
+// +typewriter:field:ignore respectively. The markers with a value, like
+// +typewriter:types:ignore=**.Internal*, add the value as pattern.
+func IgnoreRulesFromMarkers(c *packages.Cache, pkgPath string) (IgnoreRules, error) {
+	p, err := c.GetPackage(pkgPath)
+	if err != nil {
+		return IgnoreRules{}, errors.Wrapf(err, "cannot get package %s", pkgPath)
+	}
+	comments := packages.LoadComments(p)
+	r := IgnoreRules{}
+	for _, name := range p.Types.Scope().Names() {
+		tn, ok := p.Types.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		cm := packages.NewCommentMarkersFromText(comments.CommentOf(tn), packages.CommentPrefix)
+		r.Types = append(r.Types, ignorePatterns(cm.Values(packages.SectionTypes, packages.TypesIgnore), QualifiedTypePath(tn))...)
+		r.Fields = append(r.Fields, ignorePatterns(cm.Values(packages.SectionField, packages.FieldIgnore), "")...)
+		s, ok := tn.Type().Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		for i := 0; i < s.NumFields(); i++ {
+			fcm := packages.NewCommentMarkersFromText(comments.CommentOf(s.Field(i)), packages.CommentPrefix)
+			r.Fields = append(r.Fields, ignorePatterns(fcm.Values(packages.SectionField, packages.FieldIgnore), QualifiedFieldPath(tn, s.Field(i).Name()))...)
+		}
+	}
+	return r, errors.Wrapf(r.Validate(), "invalid ignore markers in package %s", pkgPath)
+}
+
+// ignorePatterns returns the values of the ignore markers with the ones
+// without a value replaced by the path of the marked object.
+func ignorePatterns(vals []string, self string) []string {
+	result := make([]string, 0, len(vals))
+	for _, v := range vals {
+		if v == "" {
+			if self == "" {
+				continue
+			}
+			// Paths cannot contain glob characters, so they match only
+			// themselves.
+			v = self
+		}
+		result = append(result, v)
+	}
+	return result
+}
+
+// TypeFilter returns a TypeFilter that drops the types matching the rules.
+func (r IgnoreRules) TypeFilter() (*IgnoreTypeFilter, error) {
+	return NewIgnoreTypeFilter(r.Types)
+}
+
+// FieldFilter returns a FieldFilter that drops the fields matching the rules.
+func (r IgnoreRules) FieldFilter() (*IgnoreFieldFilter, error) {
+	return NewIgnoreFieldFilter(r.Fields)
+}
+
+// NewIgnoreTypeFilter returns a TypeFilter that drops the types matching any
+// of the patterns. See IgnoreRules for the pattern format.
+func NewIgnoreTypeFilter(patterns []string) (*IgnoreTypeFilter, error) {
+	ps, err := compileTypePatterns(patterns)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid type pattern")
 	}
-	return ig
+	return &IgnoreTypeFilter{patterns: ps}, nil
 }
 
 type IgnoreTypeFilter struct {
-	ignore map[string]struct{}
+	patterns []pattern
 }
 
-func (ig *IgnoreTypeFilter) Filter(name types.TypeName, t types.Type) types.Type {
-	if _, ok := ig.ignore[name.Name()]; ok {
-		return nil
+func (ig *IgnoreTypeFilter) Filter(t *types.Named) *types.Named {
+	if t.Obj().Pkg() == nil {
+		return t
+	}
+	path := QualifiedTypePath(t.Obj())
+	for _, p := range ig.patterns {
+		if p.match(path, t.Obj().Name()) {
+			return nil
+		}
 	}
 	return t
 }
 
-func NewIgnoreFieldFilter(list []string) *IgnoreFieldFilter {
-	ig := &IgnoreFieldFilter{}
-	for _, t := range list {
-		ig.ignore[t] = struct{}{}
+// NewIgnoreFieldFilter returns a FieldFilter that drops the fields matching
+// any of the patterns. See IgnoreRules for the pattern format.
+func NewIgnoreFieldFilter(patterns []string) (*IgnoreFieldFilter, error) {
+	ps, err := compileFieldPatterns(patterns)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid field pattern")
 	}
-	return ig
+	return &IgnoreFieldFilter{patterns: ps}, nil
 }
 
 type IgnoreFieldFilter struct {
-	ignore map[string]struct{}
+	patterns []pattern
 }
 
-func (ig *IgnoreFieldFilter) Filter(field *types.Var, tag string) (*types.Var, string) {
-	if _, ok := ig.ignore[field.Name()]; ok {
-		return nil, ""
+func (ig *IgnoreFieldFilter) Filter(owner FieldOwner, field *types.Var, tag string) (*types.Var, string) {
+	path := field.Name()
+	if owner.Type != nil && owner.Type.Pkg() != nil {
+		path = QualifiedFieldPath(owner.Type, field.Name())
+	}
+	for _, p := range ig.patterns {
+		if !p.nested {
+			if p.match(path, field.Name()) {
+				return nil, ""
+			}
+			continue
+		}
+		for _, op := range owner.Paths {
+			if p.re.MatchString(op + "." + field.Name()) {
+				return nil, ""
+			}
+		}
 	}
 	return field, tag
 }
+
+// UsesFieldPaths returns true if any of the patterns is a nested field path.
+func (ig *IgnoreFieldFilter) UsesFieldPaths() bool {
+	for _, p := range ig.patterns {
+		if p.nested {
+			return true
+		}
+	}
+	return false
+}
+
+// pattern is a compiled glob that is matched against either the full path or
+// the name only, or the paths of nested fields.
+type pattern struct {
+	re       *regexp.Regexp
+	nameOnly bool
+	nested   bool
+}
+
+func (p pattern) match(path, name string) bool {
+	if p.nameOnly {
+		return p.re.MatchString(name)
+	}
+	return p.re.MatchString(path)
+}
+
+// compileTypePatterns compiles the globs of the types. The ones without "."
+// are matched against the name only. It returns error for the globs whose
+// name part cannot match a Go identifier, which would silently match nothing.
+func compileTypePatterns(globs []string) ([]pattern, error) {
+	result := make([]pattern, len(globs))
+	for i, g := range globs {
+		name := g[strings.LastIndex(g, ".")+1:]
+		if name == "" || strings.ContainsAny(name, "/:") {
+			return nil, errors.Errorf("pattern %q should be either a name or a path in <package path>.<name> format", g)
+		}
+		result[i] = pattern{re: regexp.MustCompile("^" + globToRegexp(g, '/') + "$"), nameOnly: name == g}
+	}
+	return result, nil
+}
+
+// compileFieldPatterns compiles the globs of the fields. The ones without ":"
+// or "." are matched against the name only and the ones whose field part has
+// "." are matched against the nested field paths. Nested paths without ":"
+// are assumed to start with a type name in any package.
+func compileFieldPatterns(globs []string) ([]pattern, error) {
+	result := make([]pattern, len(globs))
+	for i, g := range globs {
+		typ, fields := "", g
+		switch j := strings.LastIndex(g, ":"); {
+		case j != -1:
+			typ, fields = g[:j], g[j+1:]
+		case strings.Contains(g, ".") && !strings.Contains(g, "/"):
+			j = strings.Index(g, ".")
+			typ, fields = "**."+g[:j], g[j+1:]
+		}
+		for _, name := range strings.Split(fields, ".") {
+			if name == "" || strings.ContainsAny(name, "/:") {
+				return nil, errors.Errorf("pattern %q should be either a name, a path in <package path>.<type name>:<field name> format or a nested field path", g)
+			}
+		}
+		if fields == g {
+			result[i] = pattern{re: regexp.MustCompile("^" + globToRegexp(g, '/') + "$"), nameOnly: true}
+			continue
+		}
+		if name := typ[strings.LastIndex(typ, ".")+1:]; name == "" || strings.Contains(name, "/") {
+			return nil, errors.Errorf("pattern %q should have a type name before the field names", g)
+		}
+		result[i] = pattern{
+			re:     regexp.MustCompile("^" + globToRegexp(typ, '/') + ":" + globToRegexp(fields, '.') + "$"),
+			nested: strings.Contains(fields, "."),
+		}
+	}
+	return result, nil
+}
+
+// globToRegexp returns the regular expression of the glob in which "*" matches
+// any sequence of characters except sep.
+func globToRegexp(g string, sep rune) string {
+	single := "[^" + regexp.QuoteMeta(string(sep)) + "]"
+	var b strings.Builder
+	r := []rune(g)
+	for i := 0; i < len(r); i++ {
+		switch {
+		case r[i] == '*' && i+1 < len(r) && r[i+1] == '*':
+			b.WriteString(".*")
+			i++
+		case r[i] == '*':
+			b.WriteString(single + "*")
+		case r[i] == '?':
+			b.WriteString(single)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r[i])))
+		}
+	}
+	return b.String()
+}
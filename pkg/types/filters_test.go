@@ -0,0 +1,168 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+	"github.com/muvaf/typewriter/pkg/test"
+)
+
+func TestIgnoreFilters(t *testing.T) {
+	api := types.NewPackage("github.com/org/sdk/api", "api")
+	nested := types.NewPackage("github.com/org/sdk/api/v1", "v1")
+	spec := types.NewTypeName(token.NoPos, api, "Spec", nil)
+	mySpec := types.NewTypeName(token.NoPos, api, "MySpec", nil)
+	internal := types.NewTypeName(token.NoPos, nested, "InternalState", nil)
+	type want struct {
+		types  []string
+		fields []string
+	}
+	cases := map[string]struct {
+		rules IgnoreRules
+		want
+	}{
+		"Empty": {
+			want: want{
+				types:  []string{"Spec", "MySpec", "InternalState"},
+				fields: []string{"Spec:Secret", "Spec:Name", "MySpec:Secret"},
+			},
+		},
+		"Names": {
+			rules: IgnoreRules{Types: []string{"Internal*"}, Fields: []string{"Secret"}},
+			want: want{
+				types:  []string{"Spec", "MySpec"},
+				fields: []string{"Spec:Name"},
+			},
+		},
+		"Paths": {
+			rules: IgnoreRules{Types: []string{"github.com/org/sdk/*.*Spec"}, Fields: []string{"**.Spec:Secret"}},
+			want: want{
+				types:  []string{"InternalState"},
+				fields: []string{"Spec:Name", "MySpec:Secret"},
+			},
+		},
+		"RecursivePaths": {
+			rules: IgnoreRules{Types: []string{"github.com/org/sdk/**.Internal*"}, Fields: []string{"github.com/org/sdk/api.*:*"}},
+			want: want{
+				types: []string{"Spec", "MySpec"},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := want{}
+			tf, err := tc.rules.TypeFilter()
+			if err != nil {
+				t.Fatalf("TypeFilter(): unexpected error: %s", err)
+			}
+			for _, tn := range []*types.TypeName{spec, mySpec, internal} {
+				if tf.Filter(types.NewNamed(tn, types.NewStruct(nil, nil), nil)) != nil {
+					got.types = append(got.types, tn.Name())
+				}
+			}
+			ff, err := tc.rules.FieldFilter()
+			if err != nil {
+				t.Fatalf("FieldFilter(): unexpected error: %s", err)
+			}
+			for _, f := range []struct {
+				owner *types.TypeName
+				name  string
+			}{{spec, "Secret"}, {spec, "Name"}, {mySpec, "Secret"}} {
+				if field, _ := ff.Filter(FieldOwner{Type: f.owner}, types.NewField(token.NoPos, api, f.name, types.Typ[types.String], false), ""); field != nil {
+					got.fields = append(got.fields, f.owner.Name()+":"+f.name)
+				}
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("Filter(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestIgnoreRulesValidate(t *testing.T) {
+	cases := map[string]struct {
+		rules IgnoreRules
+		err   bool
+	}{
+		"Valid": {
+			rules: IgnoreRules{Types: []string{"Internal*", "github.com/org/sdk/**.Internal*"}, Fields: []string{"Secret", "**.Spec:Secret"}},
+		},
+		"NestedFieldPaths": {
+			rules: IgnoreRules{Fields: []string{"Spec.*.Secret", "**.Spec:Inner.Secret"}},
+		},
+		"EmptyNestedFieldName": {
+			rules: IgnoreRules{Fields: []string{"Spec..Secret"}},
+			err:   true,
+		},
+		"FieldPathWithoutField": {
+			rules: IgnoreRules{Fields: []string{"github.com/org/sdk.Spec"}},
+			err:   true,
+		},
+		"FieldPathWithoutType": {
+			rules: IgnoreRules{Fields: []string{"github.com/org/sdk/:Secret"}},
+			err:   true,
+		},
+		"TypePathWithoutName": {
+			rules: IgnoreRules{Types: []string{"github.com/org/sdk/**"}},
+			err:   true,
+		},
+		"EmptyFieldName": {
+			rules: IgnoreRules{Fields: []string{"**.Spec:"}},
+			err:   true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.rules.Validate()
+			if (err != nil) != tc.err {
+				t.Errorf("Validate(): unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestIgnoreRulesFromMarkers(t *testing.T) {
+	p := test.ParsePackage(`
+package test
+
+// +typewriter:types:ignore
+type Internal struct{}
+
+// +typewriter:types:ignore=**.Deprecated*
+// +typewriter:field:ignore=Secret
+type Spec struct {
+	// +typewriter:field:ignore
+	Token string
+	Name  string
+}
+`)
+	got, err := IgnoreRulesFromMarkers(packages.NewCache(p), p.PkgPath)
+	if err != nil {
+		t.Fatalf("IgnoreRulesFromMarkers(...): unexpected error: %s", err)
+	}
+	want := IgnoreRules{
+		Types:  []string{"simple.go.Internal", "**.Deprecated*"},
+		Fields: []string{"Secret", "simple.go.Spec:Token"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("IgnoreRulesFromMarkers(...): -want, +got:\n%s", diff)
+	}
+}
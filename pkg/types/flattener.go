@@ -15,9 +15,11 @@
 package types
 
 import (
-	"fmt"
 	"go/token"
 	"go/types"
+	"sort"
+
+	"github.com/pkg/errors"
 
 	"github.com/muvaf/typewriter/pkg/packages"
 )
@@ -26,8 +28,33 @@ type TypeFilter interface {
 	Filter(*types.Named) *types.Named
 }
 
+// FieldFilter is called for every field of the copied types together with the
+// type that declares it. It can drop the field by returning nil, or return a
+// new field with a different name or type together with a different tag. The
+// named types the new field refers to are copied as well if they're in the
+// local or remote packages.
 type FieldFilter interface {
-	Filter(field *types.Var, tag string) (*types.Var, string)
+	Filter(owner FieldOwner, field *types.Var, tag string) (*types.Var, string)
+}
+
+// FieldPathFilter is implemented by the FieldFilters that match the fields by
+// the paths of nested fields they're reached through. The Flattener computes
+// FieldOwner.Paths only if its FieldFilter uses them.
+type FieldPathFilter interface {
+	UsesFieldPaths() bool
+}
+
+// FieldOwner is the type that declares the field given to a FieldFilter.
+type FieldOwner struct {
+	// Type is the type that declares the field.
+	Type *types.TypeName
+
+	// Paths are the paths of the nested fields the type is reached through
+	// from the other struct types that are copied, in
+	// <package path>.<type name>:<field name>.<field name>... format. For
+	// example, the type of Template field of Spec has
+	// example.com/sdk.Spec:Template among its paths.
+	Paths []string
 }
 
 func WithTypeFilters(tf ...TypeFilter) FlattenerOption {
@@ -44,7 +71,15 @@ func WithFieldFilters(ff ...FieldFilter) FlattenerOption {
 
 func WithRemotePkgPath(path string) FlattenerOption {
 	return func(f *Flattener) {
-		f.RemotePkgPath = path
+		f.RemotePkgPaths = append(f.RemotePkgPaths, path)
+	}
+}
+
+// WithRemotePkgPaths adds the paths of the packages whose types should be
+// copied to the local package.
+func WithRemotePkgPaths(paths ...string) FlattenerOption {
+	return func(f *Flattener) {
+		f.RemotePkgPaths = append(f.RemotePkgPaths, paths...)
 	}
 }
 
@@ -54,13 +89,111 @@ func WithLocalPkg(pkg *types.Package) FlattenerOption {
 	}
 }
 
+// WithFieldVisibility sets the policy for copying unexported fields. By default,
+// they are copied only if the remote type is in the local package.
+func WithFieldVisibility(v packages.FieldVisibility) FlattenerOption {
+	return func(f *Flattener) {
+		f.FieldVisibility = v
+	}
+}
+
+// WithFlattenComments makes the flattener carry the comments of the remote
+// types and their fields to the local ones.
+func WithFlattenComments(ct *CommentTransfer) FlattenerOption {
+	return func(f *Flattener) {
+		f.CommentTransfer = ct
+	}
+}
+
+// WithRenameFn sets the function that names the local copies of the remote
+// types whose names are taken by other copies. By default, the name of the
+// package of the remote type is used as prefix.
+func WithRenameFn(fn RenameFn) FlattenerOption {
+	return func(f *Flattener) {
+		f.RenameFn = fn
+	}
+}
+
+// WithTypeNamePrefix adds the prefix to the names of the local copies of the
+// remote types, i.e. EC2Instance for ec2.Instance with "EC2".
+func WithTypeNamePrefix(p string) FlattenerOption {
+	return func(f *Flattener) {
+		f.NamePrefix = p
+	}
+}
+
+// WithTypeNameSuffix adds the suffix to the names of the local copies of the
+// remote types.
+func WithTypeNameSuffix(s string) FlattenerOption {
+	return func(f *Flattener) {
+		f.NameSuffix = s
+	}
+}
+
+// WithTypeNames sets the names of the local copies of the remote types. The
+// keys are the full paths of the remote types in <package path>.<type name>
+// format. It takes precedence over the prefix and suffix.
+func WithTypeNames(m map[string]string) FlattenerOption {
+	return func(f *Flattener) {
+		f.TypeNames = m
+	}
+}
+
+// WithRenameMarkers makes the flattener use the names given in the rename
+// markers of the remote types, i.e. +typewriter:types:rename=EC2Instance. The
+// markers take precedence over all other naming options.
+func WithRenameMarkers(cc *packages.CommentCache) FlattenerOption {
+	return func(f *Flattener) {
+		f.MarkerCache = cc
+	}
+}
+
 type FlattenerOption func(*Flattener)
 
+// WithEnumConstants makes the flattener copy the constants of the remote types
+// together with their grouping and comments so that the enums keep their
+// values in the local package.
+func WithEnumConstants(c *packages.Cache) FlattenerOption {
+	return func(f *Flattener) {
+		f.ConstantCache = c
+	}
+}
+
+// WithFlattenMethods makes the flattener copy the source code of the methods of the
+// remote types with the references to the remote package rewritten. It should
+// be used together with WithEnumConstants if the methods use the constants of
+// the copied types. The cache should be created with
+// packages.LoadModeWithTypesInfo and the flattened types must be loaded from
+// it since the references are resolved by the identity of the types.
+func WithFlattenMethods(c *packages.Cache) FlattenerOption {
+	return func(f *Flattener) {
+		f.MethodCache = c
+	}
+}
+
+// WithFlattenDeclaredTypes makes the flattener keep the named types that the
+// remote types are declared with, like Base in `type Derived Base`, instead of
+// their underlying types. The declarations are read from the given cache and
+// the flattened types must be loaded from it.
+func WithFlattenDeclaredTypes(c *packages.Cache) FlattenerOption {
+	return func(f *Flattener) {
+		f.DeclarationCache = c
+	}
+}
+
 func NewFlattener(im *packages.Imports, opts ...FlattenerOption) *Flattener {
 	f := &Flattener{
 		Imports:     im,
 		TypeFilter:  NopTypeFilter{},
 		FieldFilter: NopFieldFilter{},
+		RenameFn:    PrefixPackageName(),
+		Comments:    Comments{},
+		Constants:   Constants{},
+		Methods:     Methods{},
+		Sources:     Sources{},
+		Declared:    DeclaredTypes{},
+		Copies:      map[*types.TypeName]*types.Named{},
+		constNames:  map[*types.Const]string{},
 	}
 	for _, opt := range opts {
 		opt(f)
@@ -68,119 +201,563 @@ func NewFlattener(im *packages.Imports, opts ...FlattenerOption) *Flattener {
 	return f
 }
 
+// Flattener copies a type and all the named types it refers to from the local
+// and remote packages into the local package. The references to the copied
+// types are rewritten to point to the copies while the types in other packages
+// are referred to as they are.
 type Flattener struct {
 	Imports *packages.Imports
-	// RemotePkgPath is the path of the package of the remote type we're pulling
-	// the types from.
-	RemotePkgPath string
-	LocalPkg      *types.Package
+	// RemotePkgPaths are the paths of the packages of the remote types we're
+	// pulling the types from.
+	RemotePkgPaths []string
+	LocalPkg       *types.Package
+
+	TypeFilter      TypeFilter
+	FieldFilter     FieldFilter
+	FieldVisibility packages.FieldVisibility
+	CommentTransfer *CommentTransfer
+	RenameFn        RenameFn
+
+	// NamePrefix, NameSuffix, TypeNames and the rename markers read using
+	// MarkerCache decide the names of the local copies of the remote types.
+	NamePrefix  string
+	NameSuffix  string
+	TypeNames   map[string]string
+	MarkerCache *packages.CommentCache
+
+	// Comments holds the comments of the local types once Flatten is called.
+	// It's filled only if CommentTransfer is given.
+	Comments Comments
+
+	// Sources holds the remote types that the local types are copied from
+	// once Flatten is called.
+	Sources Sources
 
-	TypeFilter  TypeFilter
-	FieldFilter FieldFilter
+	// Copies holds the local copies of the types indexed by the types they're
+	// copied from. The types that exist in it are not copied again so that the
+	// types shared by the types given to many Flatten calls are copied once.
+	Copies map[*types.TypeName]*types.Named
+
+	// ConstantCache is used to load the declarations of the constants of the
+	// remote types. Constants holds the copies of them once Flatten is called.
+	ConstantCache *packages.Cache
+	Constants     Constants
+
+	// MethodCache is used to load the source code of the methods of the remote
+	// types. Methods holds the copies of them once Flatten is called.
+	MethodCache *packages.Cache
+	Methods     Methods
+
+	// DeclarationCache is used to load the declarations of the remote types.
+	// Declared holds the local named types that the copies are declared with
+	// once Flatten is called.
+	DeclarationCache *packages.Cache
+	Declared         DeclaredTypes
+
+	constNames map[*types.Const]string
+	fieldPaths map[*types.TypeName][]string
 }
 
-func (f *Flattener) Flatten(t *types.Named) []*types.Named {
-	typeMap := map[types.TypeName]*types.Named{}
-	f.load(typeMap, t)
-	result := make([]*types.Named, len(typeMap))
-	i := 0
-	for _, n := range typeMap {
-		result[i] = n
-		i++
+// Flatten returns the local copies of the given type and the types it refers
+// to in the order they are found. The given type is copied even if it's not in
+// the local or remote packages.
+func (f *Flattener) Flatten(t *types.Named) ([]*types.Named, error) {
+	if fp, ok := f.FieldFilter.(FieldPathFilter); ok && fp.UsesFieldPaths() {
+		f.fieldPaths = f.collectFieldPaths(t)
 	}
-	return result
+	var all []*types.Named
+	visited := map[*types.TypeName]struct{}{}
+	f.collect(t, t.Origin().Obj(), visited, &all)
+	declared := map[*types.TypeName]*types.Named{}
+	if f.DeclarationCache != nil {
+		// The named types that the types are declared with are copied, too, so
+		// that the copies can be declared with their copies.
+		for i := 0; i < len(all); i++ {
+			d, err := f.declaredType(all[i])
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot get declaration of type %s", all[i].Obj().Name())
+			}
+			if d != nil {
+				declared[all[i].Obj()] = d
+				f.collect(d, t.Origin().Obj(), visited, &all)
+			}
+		}
+	}
+	// The types copied by the previous calls are reused as they are.
+	var sources []*types.Named
+	for _, src := range all {
+		if _, ok := f.Copies[src.Obj()]; !ok {
+			sources = append(sources, src)
+		}
+	}
+	copies, err := f.newCopies(sources)
+	if err != nil {
+		return nil, err
+	}
+	for src, c := range f.Copies {
+		copies[src] = c
+	}
+	for _, src := range sources {
+		c := copies[src.Obj()]
+		if err := f.fill(copies, src, c); err != nil {
+			return nil, errors.Wrapf(err, "cannot copy type %s", src.Obj().Name())
+		}
+		f.Copies[src.Obj()] = c
+		if d, ok := declared[src.Obj()]; ok {
+			f.Declared[QualifiedTypePath(c.Obj())] = f.localType(copies, d)
+		}
+		if !f.isLocal(src) {
+			f.Sources[QualifiedTypePath(c.Obj())] = src.Obj()
+		}
+	}
+	result := make([]*types.Named, len(all))
+	for i, src := range all {
+		result[i] = copies[src.Obj()]
+	}
+	if f.ConstantCache != nil {
+		if err := f.copyAllConstants(sources, copies); err != nil {
+			return nil, err
+		}
+	}
+	if f.MethodCache != nil {
+		for _, src := range sources {
+			if f.isLocal(src) || src.TypeArgs().Len() != 0 {
+				continue
+			}
+			if err := f.copyMethods(src, copies); err != nil {
+				return nil, errors.Wrapf(err, "cannot copy methods of type %s", src.Obj().Name())
+			}
+		}
+	}
+	return result, nil
 }
 
-func (f *Flattener) load(m map[types.TypeName]*types.Named, t *types.Named) {
-	t = f.TypeFilter.Filter(t)
-	if t == nil {
-		return
+// copyAllConstants copies the constants of the remote types whose underlying
+// types are basic. The constants are renamed if their names are taken.
+func (f *Flattener) copyAllConstants(sources []*types.Named, copies map[*types.TypeName]*types.Named) error {
+	taken := map[string]struct{}{}
+	for _, c := range copies {
+		taken[c.Obj().Name()] = struct{}{}
 	}
-	s, ok := t.Underlying().(*types.Struct)
-	if !ok {
-		// TODO(muvaf): If the underlying type is not Struct, it means it's
-		// likely enum, which doesn't have fields, hence no field iteration.
-		// However, if it points to a named type instead of a basic one, we're
-		// skipping it.
-		// TODO(muvaf): naming collisions? is it possible this function runs
-		// with multiple packages?
-		b, ok := t.Underlying().(*types.Basic)
+	for _, name := range f.constNames {
+		taken[name] = struct{}{}
+	}
+	isTaken := func(name string) bool {
+		if _, ok := taken[name]; ok {
+			return true
+		}
+		return f.LocalPkg != nil && f.LocalPkg.Scope().Lookup(name) != nil
+	}
+	take := func(name string) { taken[name] = struct{}{} }
+	for _, src := range sources {
+		// Constants of the local types exist in the local package already and
+		// generic types cannot have constants.
+		if f.isLocal(src) || src.TypeParams().Len() != 0 || src.TypeArgs().Len() != 0 {
+			continue
+		}
+		if _, ok := src.Underlying().(*types.Basic); !ok {
+			continue
+		}
+		if err := f.copyConstants(src, copies[src.Obj()], isTaken, take); err != nil {
+			return errors.Wrapf(err, "cannot copy constants of type %s", src.Obj().Name())
+		}
+	}
+	return nil
+}
+
+// collect adds the named types that should be copied to the local package to
+// the list in the order they're found.
+func (f *Flattener) collect(t types.Type, root *types.TypeName, visited map[*types.TypeName]struct{}, result *[]*types.Named) {
+	switch u := t.(type) {
+	case *types.Named:
+		n := f.TypeFilter.Filter(u)
+		if n == nil {
+			return
+		}
+		// Instances of a generic type are localized as the generic type itself
+		// and the type arguments are collected separately.
+		if n.TypeArgs().Len() != 0 {
+			for i := 0; i < n.TypeArgs().Len(); i++ {
+				f.collect(n.TypeArgs().At(i), root, visited, result)
+			}
+			f.collect(n.Origin(), root, visited, result)
+			return
+		}
+		if _, ok := visited[n.Obj()]; ok || (n.Obj() != root && !f.isCopied(n)) {
+			return
+		}
+		visited[n.Obj()] = struct{}{}
+		*result = append(*result, n)
+		s, ok := n.Underlying().(*types.Struct)
 		if !ok {
-			fmt.Printf("only types whose underlying is struct or basic are supported, skipping %s\n", t.Obj().Name())
+			f.collect(n.Underlying(), root, visited, result)
+			return
 		}
-		ntn := types.NewTypeName(token.NoPos, f.LocalPkg, t.Obj().Name(), nil)
-		methods := make([]*types.Func, t.NumMethods())
-		for j := 0; j < t.NumMethods(); j++ {
-			methods[j] = t.Method(j)
+		for i := 0; i < s.NumFields(); i++ {
+			if field, _ := f.field(n.Obj(), s, i); field != nil {
+				f.collect(field.Type(), root, visited, result)
+			}
 		}
-		m[*ntn] = types.NewNamed(ntn, b, methods)
-		return
+	case *types.Pointer:
+		f.collect(u.Elem(), root, visited, result)
+	case *types.Slice:
+		f.collect(u.Elem(), root, visited, result)
+	case *types.Array:
+		f.collect(u.Elem(), root, visited, result)
+	case *types.Map:
+		f.collect(u.Key(), root, visited, result)
+		f.collect(u.Elem(), root, visited, result)
+	case *types.Chan:
+		f.collect(u.Elem(), root, visited, result)
+	}
+}
+
+// collectFieldPaths returns the paths of the nested fields that the types to
+// be copied are reached through from the struct types, see FieldOwner. The
+// fields are filtered without the paths while walking, so the fields that are
+// dropped only because of their paths are still walked.
+func (f *Flattener) collectFieldPaths(t *types.Named) map[*types.TypeName][]string {
+	seen := map[*types.TypeName]map[string]struct{}{}
+	f.walkFieldPaths(t, t.Origin().Obj(), nil, map[*types.TypeName]struct{}{}, seen)
+	result := make(map[*types.TypeName][]string, len(seen))
+	for tn, paths := range seen {
+		for p := range paths {
+			result[tn] = append(result[tn], p)
+		}
+		sort.Strings(result[tn])
+	}
+	return result
+}
+
+// walkFieldPaths adds the paths to the named types found in t and continues
+// with the fields of the structs. The types on the current route are skipped
+// to break the cycles, and so are the ones that have all given paths already.
+func (f *Flattener) walkFieldPaths(t types.Type, root *types.TypeName, paths []string, route map[*types.TypeName]struct{}, seen map[*types.TypeName]map[string]struct{}) {
+	switch u := t.(type) {
+	case *types.Named:
+		n := f.TypeFilter.Filter(u)
+		if n == nil {
+			return
+		}
+		if n.TypeArgs().Len() != 0 {
+			for i := 0; i < n.TypeArgs().Len(); i++ {
+				f.walkFieldPaths(n.TypeArgs().At(i), root, paths, route, seen)
+			}
+			f.walkFieldPaths(n.Origin(), root, paths, route, seen)
+			return
+		}
+		if _, ok := route[n.Obj()]; ok || (n.Obj() != root && !f.isCopied(n)) {
+			return
+		}
+		known, visited := seen[n.Obj()]
+		if !visited {
+			known = map[string]struct{}{}
+			seen[n.Obj()] = known
+		}
+		added := false
+		for _, p := range paths {
+			if _, ok := known[p]; !ok {
+				known[p] = struct{}{}
+				added = true
+			}
+		}
+		if visited && !added {
+			return
+		}
+		route[n.Obj()] = struct{}{}
+		defer delete(route, n.Obj())
+		s, ok := n.Underlying().(*types.Struct)
+		if !ok {
+			f.walkFieldPaths(n.Underlying(), root, paths, route, seen)
+			return
+		}
+		for i := 0; i < s.NumFields(); i++ {
+			if field, _ := f.filterField(FieldOwner{Type: n.Obj()}, s, i); field == nil {
+				continue
+			}
+			name := s.Field(i).Name()
+			next := make([]string, 0, len(paths)+1)
+			next = append(next, QualifiedFieldPath(n.Obj(), name))
+			for _, p := range paths {
+				next = append(next, p+"."+name)
+			}
+			f.walkFieldPaths(s.Field(i).Type(), root, next, route, seen)
+		}
+	case *types.Pointer:
+		f.walkFieldPaths(u.Elem(), root, paths, route, seen)
+	case *types.Slice:
+		f.walkFieldPaths(u.Elem(), root, paths, route, seen)
+	case *types.Array:
+		f.walkFieldPaths(u.Elem(), root, paths, route, seen)
+	case *types.Map:
+		f.walkFieldPaths(u.Key(), root, paths, route, seen)
+		f.walkFieldPaths(u.Elem(), root, paths, route, seen)
+	case *types.Chan:
+		f.walkFieldPaths(u.Elem(), root, paths, route, seen)
+	}
+}
+
+// newCopies returns the local types without their underlying types for given
+// source types so that the types referring to each other can be filled. The
+// types that are in the local package already keep their names. The remote
+// ones are named according to the naming options and renamed if their names
+// are taken by the other copies. The existing types of the local package are
+// not considered since the printer compares their structures with the copies
+// and reuses them if they're the same, see DuplicateStrategy.
+func (f *Flattener) newCopies(sources []*types.Named) (map[*types.TypeName]*types.Named, error) {
+	taken := map[string]struct{}{}
+	// Copies made by the previous calls are not in the local package yet.
+	for _, c := range f.Copies {
+		taken[c.Obj().Name()] = struct{}{}
+	}
+	isTaken := func(name string) bool {
+		_, ok := taken[name]
+		return ok
+	}
+	names := map[*types.TypeName]string{}
+	for _, src := range sources {
+		if f.isLocal(src) {
+			names[src.Obj()] = src.Obj().Name()
+			taken[src.Obj().Name()] = struct{}{}
+		}
+	}
+	for _, src := range sources {
+		if f.isLocal(src) {
+			continue
+		}
+		name, explicit, err := f.localName(src.Obj())
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get local name of type %s", src.Obj().Name())
+		}
+		if isTaken(name) {
+			if explicit {
+				return nil, errors.Errorf("name %s given to type %s is already taken", name, QualifiedTypePath(src.Obj()))
+			}
+			name = f.RenameFn(name, src.Obj(), isTaken)
+		}
+		names[src.Obj()] = name
+		taken[name] = struct{}{}
+	}
+	result := make(map[*types.TypeName]*types.Named, len(sources))
+	for _, src := range sources {
+		ntn := types.NewTypeName(token.NoPos, f.LocalPkg, names[src.Obj()], nil)
+		methods := make([]*types.Func, src.NumMethods())
+		for j := 0; j < src.NumMethods(); j++ {
+			methods[j] = src.Method(j)
+		}
+		nn := types.NewNamed(ntn, nil, methods)
+		nn.SetTypeParams(cloneTypeParams(f.LocalPkg, src.TypeParams()))
+		result[src.Obj()] = nn
+	}
+	return result, nil
+}
+
+// localName returns the name of the local copy of given remote type and
+// whether it's given explicitly.
+func (f *Flattener) localName(remote *types.TypeName) (string, bool, error) {
+	if f.MarkerCache != nil {
+		c, err := f.MarkerCache.CommentOf(remote)
+		if err != nil {
+			return "", false, errors.Wrap(err, "cannot get comment")
+		}
+		cm := packages.NewCommentMarkersFromText(c, packages.CommentPrefix)
+		if n, ok := cm.Get(packages.SectionTypes, packages.TypesRename); ok && n != "" {
+			return n, true, nil
+		}
+	}
+	if n, ok := f.TypeNames[QualifiedTypePath(remote)]; ok {
+		return n, true, nil
+	}
+	return f.NamePrefix + remote.Name() + f.NameSuffix, false, nil
+}
+
+// fill sets the underlying type of the copy with the references to the copied
+// types rewritten.
+func (f *Flattener) fill(copies map[*types.TypeName]*types.Named, src, c *types.Named) error {
+	if err := f.addTypeComment(c.Obj(), src.Obj()); err != nil {
+		return errors.Wrap(err, "cannot transfer type comment")
+	}
+	s, ok := src.Underlying().(*types.Struct)
+	if !ok {
+		c.SetUnderlying(f.localType(copies, src.Underlying()))
+		return nil
 	}
 	var fields []*types.Var
 	var tags []string
 	for i := 0; i < s.NumFields(); i++ {
-		// TODO(muvaf): Make this optional.
-		if !s.Field(i).Exported() {
-			continue
-		}
-		field, tag := f.FieldFilter.Filter(s.Field(i), s.Tag(i))
+		field, tag := f.field(src.Obj(), s, i)
 		if field == nil {
 			continue
 		}
-		switch u := field.Type().(type) {
-		case *types.Pointer:
-			newElem := u.Elem()
-			if n, ok := u.Elem().(*types.Named); ok {
-				f.load(m, n)
-				if n.Obj().Pkg().Path() == f.RemotePkgPath {
-					newElem = NewNamedInLocalPkg(n, f.LocalPkg)
-				}
-			}
-			field = types.NewField(field.Pos(), f.LocalPkg, field.Name(), types.NewPointer(newElem), field.Embedded())
-		case *types.Slice:
-			newElem := u.Elem()
-			switch n := u.Elem().(type) {
-			case *types.Named:
-				f.load(m, n)
-				if n.Obj().Pkg().Path() == f.RemotePkgPath {
-					newElem = NewNamedInLocalPkg(n, f.LocalPkg)
-				}
-			case *types.Pointer:
-				if pn, ok := n.Elem().(*types.Named); ok {
-					f.load(m, pn)
-					if pn.Obj().Pkg().Path() == f.RemotePkgPath {
-						newElem = types.NewPointer(NewNamedInLocalPkg(pn, f.LocalPkg))
-					}
-				}
+		if err := f.addFieldComment(c.Obj(), field.Name(), s.Field(i)); err != nil {
+			return errors.Wrapf(err, "cannot transfer comment of field %s", field.Name())
+		}
+		fields = append(fields, types.NewField(field.Pos(), f.LocalPkg, field.Name(), f.localType(copies, field.Type()), field.Embedded()))
+		tags = append(tags, tag)
+	}
+	c.SetUnderlying(types.NewStruct(fields, tags))
+	return nil
+}
+
+// field returns the field with given index after the visibility policy and the
+// field filters are applied. Nil is returned if the field should be dropped.
+func (f *Flattener) field(owner *types.TypeName, s *types.Struct, i int) (*types.Var, string) {
+	return f.filterField(FieldOwner{Type: owner, Paths: f.fieldPaths[owner]}, s, i)
+}
+
+func (f *Flattener) filterField(owner FieldOwner, s *types.Struct, i int) (*types.Var, string) {
+	if !f.FieldVisibility.Includes(s.Field(i), f.localPkgPath()) {
+		return nil, ""
+	}
+	return f.FieldFilter.Filter(owner, s.Field(i), s.Tag(i))
+}
+
+// localType returns the given type with the references to the copied types
+// replaced with their copies.
+func (f *Flattener) localType(copies map[*types.TypeName]*types.Named, t types.Type) types.Type {
+	switch u := t.(type) {
+	case *types.Named:
+		n := f.TypeFilter.Filter(u)
+		if n == nil {
+			return u
+		}
+		if n.TypeArgs().Len() != 0 {
+			origin, ok := copies[n.Origin().Obj()]
+			if !ok {
+				return u
 			}
-			field = types.NewField(field.Pos(), f.LocalPkg, field.Name(), types.NewSlice(newElem), field.Embedded())
-		case *types.Named:
-			newNamed := u
-			f.load(m, u)
-			if u.Obj().Pkg().Path() == f.RemotePkgPath {
-				newNamed = NewNamedInLocalPkg(u, f.LocalPkg)
+			targs := make([]types.Type, n.TypeArgs().Len())
+			for i := 0; i < n.TypeArgs().Len(); i++ {
+				targs[i] = f.localType(copies, n.TypeArgs().At(i))
 			}
-			field = types.NewField(field.Pos(), f.LocalPkg, field.Name(), newNamed, field.Embedded())
-		default:
-			field = types.NewField(field.Pos(), f.LocalPkg, field.Name(), field.Type(), field.Embedded())
+			return instantiate(origin, targs)
 		}
-		fields = append(fields, field)
-		tags = append(tags, tag)
+		if c, ok := copies[n.Obj()]; ok {
+			return c
+		}
+		return u
+	case *types.Pointer:
+		return types.NewPointer(f.localType(copies, u.Elem()))
+	case *types.Slice:
+		return types.NewSlice(f.localType(copies, u.Elem()))
+	case *types.Array:
+		return types.NewArray(f.localType(copies, u.Elem()), u.Len())
+	case *types.Map:
+		return types.NewMap(f.localType(copies, u.Key()), f.localType(copies, u.Elem()))
+	case *types.Chan:
+		return types.NewChan(u.Dir(), f.localType(copies, u.Elem()))
+	default:
+		return t
 	}
-	ns := types.NewStruct(fields, tags)
-	ntn := types.NewTypeName(token.NoPos, f.LocalPkg, t.Obj().Name(), nil)
-	methods := make([]*types.Func, t.NumMethods())
-	for j := 0; j < t.NumMethods(); j++ {
-		methods[j] = t.Method(j)
+}
+
+// isCopied returns true if the type is in the local package or one of the
+// remote packages.
+func (f *Flattener) isCopied(n *types.Named) bool {
+	if n.Obj().Pkg() == nil {
+		return false
 	}
-	m[*ntn] = types.NewNamed(ntn, ns, methods)
+	if f.isLocal(n) {
+		return true
+	}
+	for _, p := range f.RemotePkgPaths {
+		if n.Obj().Pkg().Path() == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Flattener) isLocal(n *types.Named) bool {
+	return n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == f.localPkgPath()
+}
+
+// addTypeComment adds the comment of the remote type to the local one unless
+// a comment is added already.
+func (f *Flattener) addTypeComment(local, remote *types.TypeName) error {
+	if f.CommentTransfer == nil {
+		return nil
+	}
+	if _, ok := f.Comments[QualifiedTypePath(local)]; ok {
+		return nil
+	}
+	c, err := f.CommentTransfer.Comment(remote)
+	if err != nil || c == "" {
+		return err
+	}
+	f.Comments.AddTypeComment(local, c)
+	return nil
+}
+
+// addFieldComment adds the comment of the remote field to the field with
+// given name in the local type.
+func (f *Flattener) addFieldComment(local *types.TypeName, name string, remoteField *types.Var) error {
+	if f.CommentTransfer == nil {
+		return nil
+	}
+	c, err := f.CommentTransfer.Comment(remoteField)
+	if err != nil || c == "" {
+		return err
+	}
+	f.Comments.AddFieldComment(local, name, c)
+	return nil
+}
+
+func (f *Flattener) localPkgPath() string {
+	if f.LocalPkg == nil {
+		return ""
+	}
+	return f.LocalPkg.Path()
 }
 
 func NewNamedInLocalPkg(t *types.Named, pkg *types.Package) *types.Named {
+	if t.TypeArgs().Len() != 0 {
+		return newInstanceInLocalPkg(t, pkg)
+	}
 	ntn := types.NewTypeName(t.Obj().Pos(), pkg, t.Obj().Name(), nil)
 	methods := make([]*types.Func, t.NumMethods())
 	for j := 0; j < t.NumMethods(); j++ {
 		methods[j] = t.Method(j)
 	}
-	return types.NewNamed(ntn, t.Underlying(), methods)
+	n := types.NewNamed(ntn, t.Underlying(), methods)
+	n.SetTypeParams(cloneTypeParams(pkg, t.TypeParams()))
+	return n
+}
+
+// newInstanceInLocalPkg instantiates the local version of the generic type of
+// given instance with its type arguments, which are localized as well if
+// they're in the same package as the generic type.
+func newInstanceInLocalPkg(t *types.Named, pkg *types.Package) *types.Named {
+	origin := NewNamedInLocalPkg(t.Origin(), pkg)
+	targs := make([]types.Type, t.TypeArgs().Len())
+	for i := 0; i < t.TypeArgs().Len(); i++ {
+		targs[i] = t.TypeArgs().At(i)
+		if n, ok := targs[i].(*types.Named); ok && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == t.Obj().Pkg().Path() {
+			targs[i] = NewNamedInLocalPkg(n, pkg)
+		}
+	}
+	return instantiate(origin, targs)
+}
+
+// instantiate returns the instance of the generic type with given type
+// arguments without validating them. Validation would require the constraints
+// to be satisfied by localized type arguments whose methods are not carried
+// over. Instantiate doesn't return error without validation.
+func instantiate(origin *types.Named, targs []types.Type) *types.Named {
+	inst, _ := types.Instantiate(nil, origin, targs, false)
+	return inst.(*types.Named)
+}
+
+// cloneTypeParams returns new type parameters with the same names and
+// constraints since a type parameter can be bound to only a single type.
+func cloneTypeParams(pkg *types.Package, tps *types.TypeParamList) []*types.TypeParam {
+	if tps.Len() == 0 {
+		return nil
+	}
+	result := make([]*types.TypeParam, tps.Len())
+	for i := 0; i < tps.Len(); i++ {
+		tp := tps.At(i)
+		result[i] = types.NewTypeParam(types.NewTypeName(tp.Obj().Pos(), pkg, tp.Obj().Name(), nil), tp.Constraint())
+	}
+	return result
 }
@@ -0,0 +1,640 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"go/format"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+	"github.com/muvaf/typewriter/pkg/test"
+)
+
+func newStructType(pkg *types.Package, name string, fields ...*types.Var) *types.Named {
+	return types.NewNamed(types.NewTypeName(token.NoPos, pkg, name, nil), types.NewStruct(fields, nil), nil)
+}
+
+func TestFlattenerFlatten(t *testing.T) {
+	ec2 := types.NewPackage("example.com/sdk/ec2", "ec2")
+	s3 := types.NewPackage("example.com/sdk/s3", "s3")
+	sdk := types.NewPackage("example.com/sdk", "sdk")
+	ec2Status := newStructType(ec2, "Status", types.NewField(token.NoPos, ec2, "Code", types.Typ[types.Int], false))
+	s3Status := newStructType(s3, "Status", types.NewField(token.NoPos, s3, "Name", types.Typ[types.String], false))
+	instance := newStructType(sdk, "Instance",
+		types.NewField(token.NoPos, sdk, "EC2", ec2Status, false),
+		types.NewField(token.NoPos, sdk, "S3", types.NewPointer(s3Status), false),
+		types.NewField(token.NoPos, sdk, "History", types.NewMap(types.Typ[types.String], types.NewSlice(s3Status)), false),
+	)
+	cases := map[string]struct {
+		remotes  []string
+		existing []string
+		opts     []FlattenerOption
+		want     map[string][]string
+		err      bool
+	}{
+		"SingleRemotePackage": {
+			remotes: []string{"example.com/sdk"},
+			want: map[string][]string{
+				"Instance": {"EC2 ec2.Status", "S3 *s3.Status", "History map[string][]s3.Status"},
+			},
+		},
+		"PrefixPackageName": {
+			remotes: []string{"example.com/sdk", "example.com/sdk/ec2", "example.com/sdk/s3"},
+			want: map[string][]string{
+				"Instance": {"EC2 local.Status", "S3 *local.S3Status", "History map[string][]local.S3Status"},
+				"Status":   {"Code int"},
+				"S3Status": {"Name string"},
+			},
+		},
+		"SuffixIndex": {
+			remotes: []string{"example.com/sdk", "example.com/sdk/ec2", "example.com/sdk/s3"},
+			opts:    []FlattenerOption{WithRenameFn(SuffixIndex())},
+			want: map[string][]string{
+				"Instance": {"EC2 local.Status", "S3 *local.Status2", "History map[string][]local.Status2"},
+				"Status":   {"Code int"},
+				"Status2":  {"Name string"},
+			},
+		},
+		"ExistingLocalType": {
+			// The printer decides what to do with the existing types after
+			// comparing their structures with the copies.
+			remotes:  []string{"example.com/sdk", "example.com/sdk/ec2", "example.com/sdk/s3"},
+			existing: []string{"Status"},
+			want: map[string][]string{
+				"Instance": {"EC2 local.Status", "S3 *local.S3Status", "History map[string][]local.S3Status"},
+				"Status":   {"Code int"},
+				"S3Status": {"Name string"},
+			},
+		},
+		"Prefix": {
+			remotes: []string{"example.com/sdk", "example.com/sdk/ec2", "example.com/sdk/s3"},
+			opts:    []FlattenerOption{WithTypeNamePrefix("SDK")},
+			want: map[string][]string{
+				"SDKInstance": {"EC2 local.SDKStatus", "S3 *local.S3SDKStatus", "History map[string][]local.S3SDKStatus"},
+				"SDKStatus":   {"Code int"},
+				"S3SDKStatus": {"Name string"},
+			},
+		},
+		"Mapping": {
+			remotes: []string{"example.com/sdk", "example.com/sdk/ec2", "example.com/sdk/s3"},
+			opts: []FlattenerOption{WithTypeNames(map[string]string{
+				"example.com/sdk.Instance":  "EC2Instance",
+				"example.com/sdk/s3.Status": "BucketStatus",
+			})},
+			want: map[string][]string{
+				"EC2Instance":  {"EC2 local.Status", "S3 *local.BucketStatus", "History map[string][]local.BucketStatus"},
+				"Status":       {"Code int"},
+				"BucketStatus": {"Name string"},
+			},
+		},
+		"MappingCollision": {
+			remotes: []string{"example.com/sdk", "example.com/sdk/ec2", "example.com/sdk/s3"},
+			opts: []FlattenerOption{WithTypeNames(map[string]string{
+				"example.com/sdk/s3.Status": "Instance",
+			})},
+			err: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			local := types.NewPackage("example.com/local", "local")
+			for _, e := range tc.existing {
+				local.Scope().Insert(types.NewTypeName(token.NoPos, local, e, types.Typ[types.String]))
+			}
+			opts := append([]FlattenerOption{WithLocalPkg(local), WithRemotePkgPaths(tc.remotes...)}, tc.opts...)
+			result, err := NewFlattener(packages.NewImports(local.Path(), local.Name()), opts...).Flatten(instance)
+			if (err != nil) != tc.err {
+				t.Fatalf("Flatten(...): unexpected error: %v", err)
+			}
+			if err != nil {
+				return
+			}
+			got := map[string][]string{}
+			for _, n := range result {
+				got[n.Obj().Name()] = fieldList(n)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Flatten(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFlattenerRenameMarkers(t *testing.T) {
+	p := test.ParsePackage(`
+package test
+
+// +typewriter:types:rename=EC2Instance
+type Instance struct {
+	Status Status
+}
+
+type Status struct {
+	Code int
+}
+`)
+	local := types.NewPackage("example.com/local", "local")
+	f := NewFlattener(packages.NewImports(local.Path(), local.Name()),
+		WithLocalPkg(local),
+		WithRemotePkgPath(p.PkgPath),
+		WithTypeNamePrefix("EC2"),
+		WithRenameMarkers(packages.NewCommentCache(packages.NewCache(p))))
+	result, err := f.Flatten(p.Types.Scope().Lookup("Instance").Type().(*types.Named))
+	if err != nil {
+		t.Fatalf("Flatten(...): unexpected error: %s", err)
+	}
+	got := map[string][]string{}
+	for _, n := range result {
+		got[n.Obj().Name()] = fieldList(n)
+	}
+	want := map[string][]string{
+		"EC2Instance": {"Status local.EC2Status"},
+		"EC2Status":   {"Code int"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Flatten(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestFlattenerNestedFieldPaths(t *testing.T) {
+	sdk := types.NewPackage("example.com/sdk", "sdk")
+	credentials := newStructType(sdk, "Credentials",
+		types.NewField(token.NoPos, sdk, "Secret", types.Typ[types.String], false),
+		types.NewField(token.NoPos, sdk, "User", types.Typ[types.String], false),
+	)
+	template := newStructType(sdk, "Template",
+		types.NewField(token.NoPos, sdk, "Name", types.Typ[types.String], false),
+		types.NewField(token.NoPos, sdk, "Secret", types.Typ[types.String], false),
+		types.NewField(token.NoPos, sdk, "Credentials", types.NewPointer(credentials), false),
+	)
+	spec := newStructType(sdk, "Spec",
+		types.NewField(token.NoPos, sdk, "Secret", types.Typ[types.String], false),
+		types.NewField(token.NoPos, sdk, "Templates", types.NewSlice(template), false),
+	)
+	auth := newStructType(sdk, "Auth",
+		types.NewField(token.NoPos, sdk, "Secret", types.Typ[types.String], false),
+	)
+	instance := newStructType(sdk, "Instance",
+		types.NewField(token.NoPos, sdk, "Spec", spec, false),
+		types.NewField(token.NoPos, sdk, "Auth", auth, false),
+	)
+	cases := map[string]struct {
+		reason string
+		fields []string
+		want   map[string][]string
+	}{
+		"SingleLevel": {
+			reason: "The field should be dropped only from the types of the fields of Spec.",
+			fields: []string{"Spec.*.Secret"},
+			want: map[string][]string{
+				"Instance":    {"Spec local.Spec", "Auth local.Auth"},
+				"Spec":        {"Secret string", "Templates []local.Template"},
+				"Template":    {"Name string", "Credentials *local.Credentials"},
+				"Credentials": {"Secret string", "User string"},
+				"Auth":        {"Secret string"},
+			},
+		},
+		"AnyLevel": {
+			reason: "The field should be dropped from all types nested under Spec.",
+			fields: []string{"example.com/sdk.Spec:**.Secret"},
+			want: map[string][]string{
+				"Instance":    {"Spec local.Spec", "Auth local.Auth"},
+				"Spec":        {"Secret string", "Templates []local.Template"},
+				"Template":    {"Name string", "Credentials *local.Credentials"},
+				"Credentials": {"User string"},
+				"Auth":        {"Secret string"},
+			},
+		},
+		"FromRoot": {
+			reason: "Paths should start from any of the types that the field is reached through.",
+			fields: []string{"Instance.Spec.Templates.Credentials.Secret"},
+			want: map[string][]string{
+				"Instance":    {"Spec local.Spec", "Auth local.Auth"},
+				"Spec":        {"Secret string", "Templates []local.Template"},
+				"Template":    {"Name string", "Secret string", "Credentials *local.Credentials"},
+				"Credentials": {"User string"},
+				"Auth":        {"Secret string"},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ff, err := IgnoreRules{Fields: tc.fields}.FieldFilter()
+			if err != nil {
+				t.Fatalf("FieldFilter(): unexpected error: %s", err)
+			}
+			local := types.NewPackage("example.com/local", "local")
+			f := NewFlattener(packages.NewImports(local.Path(), local.Name()),
+				WithLocalPkg(local),
+				WithRemotePkgPath(sdk.Path()),
+				WithFieldFilters(ff))
+			result, err := f.Flatten(instance)
+			if err != nil {
+				t.Fatalf("\n%s\nFlatten(...): unexpected error: %s", tc.reason, err)
+			}
+			got := map[string][]string{}
+			for _, n := range result {
+				got[n.Obj().Name()] = fieldList(n)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nFlatten(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestFlattenerTypeTransform(t *testing.T) {
+	stdtime := types.NewPackage("example.com/time", "time")
+	meta := types.NewPackage("example.com/sdk/meta", "meta")
+	sdk := types.NewPackage("example.com/sdk", "sdk")
+	timeType := newStructType(stdtime, "Time", types.NewField(token.NoPos, stdtime, "wall", types.Typ[types.Uint64], false))
+	metaTime := newStructType(meta, "Time", types.NewField(token.NoPos, meta, "Time", timeType, true))
+	// Other types in the package of the replacement type should still have
+	// their fields replaced.
+	condition := newStructType(meta, "Condition", types.NewField(token.NoPos, meta, "LastTransition", timeType, false))
+	instance := types.NewNamed(types.NewTypeName(token.NoPos, sdk, "Instance", nil), types.NewStruct([]*types.Var{
+		types.NewField(token.NoPos, sdk, "Created", timeType, false),
+		types.NewField(token.NoPos, sdk, "Updates", types.NewSlice(types.NewPointer(timeType)), false),
+		types.NewField(token.NoPos, sdk, "Data", types.NewSlice(types.Typ[types.Byte]), false),
+		types.NewField(token.NoPos, sdk, "Count", types.Typ[types.Int], false),
+		types.NewField(token.NoPos, sdk, "Conditions", types.NewSlice(condition), false),
+	}, []string{"", "", "", `json:"count,omitempty"`, ""}), nil)
+	local := types.NewPackage("example.com/local", "local")
+	f := NewFlattener(packages.NewImports(local.Path(), local.Name()),
+		WithLocalPkg(local),
+		WithRemotePkgPaths(sdk.Path(), meta.Path()),
+		WithFieldFilters(NewTypeTransformFilter(
+			ReplaceType(timeType, metaTime),
+			ReplaceType(types.NewSlice(types.Typ[types.Byte]), types.Typ[types.String]),
+			MakePointer(HasTagOption("json", "omitempty")),
+		)))
+	result, err := f.Flatten(instance)
+	if err != nil {
+		t.Fatalf("Flatten(...): unexpected error: %s", err)
+	}
+	got := map[string][]string{}
+	for _, n := range result {
+		got[n.Obj().Name()] = fieldList(n)
+	}
+	want := map[string][]string{
+		"Instance":  {"Created local.Time", "Updates []*local.Time", "Data string", "Count *int", "Conditions []local.Condition"},
+		"Time":      {"Time time.Time"},
+		"Condition": {"LastTransition local.Time"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Flatten(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestFlattenerExistingLocalTypes(t *testing.T) {
+	ec2 := types.NewPackage("example.com/sdk/ec2", "ec2")
+	sdk := types.NewPackage("example.com/sdk", "sdk")
+	status := newStructType(ec2, "Status", types.NewField(token.NoPos, ec2, "Code", types.Typ[types.Int], false))
+	instance := newStructType(sdk, "Instance", types.NewField(token.NoPos, sdk, "EC2", status, false))
+	cases := map[string]struct {
+		existing string
+		strategy DuplicateStrategy
+		want     string
+		err      bool
+	}{
+		"Identical": {
+			existing: `package test
+
+type Status struct {
+	Code int
+}
+`,
+			want: `package test
+
+type Instance struct {
+	EC2 Status
+}
+`,
+		},
+		"Different": {
+			existing: `package test
+
+type Status struct {
+	Code string
+}
+`,
+			err: true,
+		},
+		"DifferentRenamed": {
+			existing: `package test
+
+type Status struct {
+	Code string
+}
+`,
+			strategy: DuplicateRename,
+			want: `package test
+
+type Instance struct {
+	EC2 Status2
+}
+
+type Status2 struct {
+	Code int
+}
+`,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			local := test.ParseString(tc.existing)
+			pkg := local.Lookup("Status").Pkg()
+			im := packages.NewImports(pkg.Path(), pkg.Name())
+			result, err := NewFlattener(im, WithLocalPkg(pkg), WithRemotePkgPaths(sdk.Path(), ec2.Path())).Flatten(instance)
+			if err != nil {
+				t.Fatalf("Flatten(...): unexpected error: %s", err)
+			}
+			out, err := NewPrinter(im, pkg.Scope(), WithDuplicateStrategy(tc.strategy)).Print(result)
+			if (err != nil) != tc.err {
+				t.Fatalf("Print(...): unexpected error: %v", err)
+			}
+			if tc.err {
+				return
+			}
+			formatted, err := format.Source([]byte("package test\n" + out))
+			if err != nil {
+				t.Fatalf("cannot format output: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, string(formatted)); diff != "" {
+				t.Errorf("Print(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFlattenerEnumConstants(t *testing.T) {
+	p := test.ParsePackage(`
+package test
+
+type Instance struct {
+	State State
+	Size  Size
+}
+
+type State int
+
+// States of the instance.
+const (
+	// Pending is the first state.
+	Pending State = iota
+	Running // Running is the second state.
+	Stopped
+)
+
+type Size string
+
+const (
+	DefaultTimeout = 10
+
+	Small Size = "small"
+	Large Size = "large"
+)
+`)
+	local := types.NewPackage("example.com/local", "local")
+	local.Scope().Insert(types.NewConst(token.NoPos, local, "Running", types.Typ[types.Int], nil))
+	f := NewFlattener(packages.NewImports(local.Path(), local.Name()),
+		WithLocalPkg(local),
+		WithRemotePkgPath(p.PkgPath),
+		WithTypeNamePrefix("EC2"),
+		WithEnumConstants(packages.NewCache(p)))
+	result, err := f.Flatten(p.Types.Scope().Lookup("Instance").Type().(*types.Named))
+	if err != nil {
+		t.Fatalf("Flatten(...): unexpected error: %s", err)
+	}
+	var states, sizes *types.Named
+	for _, n := range result {
+		switch n.Obj().Name() {
+		case "EC2State":
+			states = n
+		case "EC2Size":
+			sizes = n
+		}
+	}
+	pr := NewPrinter(packages.NewImports(local.Path(), local.Name()), types.NewScope(nil, token.NoPos, token.NoPos, ""), WithConstants(f.Constants))
+	want := map[string]string{
+		"EC2State": `
+
+// States of the instance.
+const (
+// Pending is the first state.
+Pending EC2State = iota
+TestRunning // Running is the second state.
+Stopped
+)`,
+		"EC2Size": `
+
+const (
+Small EC2Size = "small"
+Large EC2Size = "large"
+)`,
+	}
+	got := map[string]string{
+		"EC2State": pr.printConstants(states),
+		"EC2Size":  pr.printConstants(sizes),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Flatten(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestFlattenerDeclaredTypes(t *testing.T) {
+	p := test.ParsePackage(`package test
+
+import "time"
+
+type Instance struct {
+	State   State
+	Timeout Timeout
+}
+
+type Base string
+
+func (b Base) String() string { return string(b) }
+
+type State Base
+
+type Timeout time.Duration
+`)
+	local := types.NewPackage("example.com/local", "local")
+	f := NewFlattener(packages.NewImports(local.Path(), local.Name()),
+		WithLocalPkg(local),
+		WithRemotePkgPath(p.PkgPath),
+		WithTypeNamePrefix("EC2"),
+		WithFlattenDeclaredTypes(packages.NewCache(p)))
+	result, err := f.Flatten(p.Types.Scope().Lookup("Instance").Type().(*types.Named))
+	if err != nil {
+		t.Fatalf("Flatten(...): unexpected error: %s", err)
+	}
+	out, err := NewPrinter(packages.NewImports(local.Path(), local.Name()), types.NewScope(nil, token.NoPos, token.NoPos, ""),
+		WithFieldOrder(OrderDeclaration),
+		WithDeclaredTypes(f.Declared)).Print(result)
+	if err != nil {
+		t.Fatalf("Print(...): unexpected error: %s", err)
+	}
+	got, err := format.Source([]byte("package local\n" + out))
+	if err != nil {
+		t.Fatalf("Print(...): output is not valid Go: %s\n%s", err, out)
+	}
+	// Base is copied since State is declared with it even though no field
+	// refers to it.
+	want := `package local
+
+type EC2Base string
+
+type EC2Instance struct {
+	State EC2State
+
+	Timeout EC2Timeout
+}
+
+type EC2State EC2Base
+
+type EC2Timeout time.Duration
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("Flatten(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestFlattenerMethods(t *testing.T) {
+	cases := map[string]struct {
+		src string
+		// otherCache makes the methods be read from a cache the flattened
+		// types are not loaded from.
+		otherCache bool
+		want       map[string][]string
+		err        bool
+	}{
+		"Rewritten": {
+			src: `
+package test
+
+import "fmt"
+
+type Instance struct {
+	Name  string
+	State State
+}
+
+// String returns the name and the state.
+func (i *Instance) String() string {
+	return fmt.Sprintf("%s: %s", i.Name, i.State)
+}
+
+type State int
+
+const (
+	Pending State = iota
+	Running
+)
+
+func (s State) String() string {
+	// Unknown states are printed as numbers.
+	switch s {
+	case Pending:
+		return "pending"
+	case Running:
+		return "running"
+	}
+	return fmt.Sprint(int(s))
+}
+`,
+			want: map[string][]string{
+				"example.com/local.EC2Instance": {`// String returns the name and the state.
+func (i *EC2Instance) String() string {
+	return fmt.Sprintf("%s: %s", i.Name, i.State)
+}`},
+				"example.com/local.EC2State": {`func (s EC2State) String() string {
+	// Unknown states are printed as numbers.
+	switch s {
+	case Pending:
+		return "pending"
+	case TestRunning:
+		return "running"
+	}
+	return fmt.Sprint(int(s))
+}`},
+			},
+		},
+		"UnexportedReference": {
+			src: `
+package test
+
+type Instance struct {
+	Name string
+}
+
+func (i Instance) Valid() bool {
+	return len(i.Name) < maxLength
+}
+
+const maxLength = 10
+`,
+			err: true,
+		},
+		"OtherCache": {
+			src: `
+package test
+
+type Instance struct {
+	Name string
+}
+
+func (i Instance) Valid() bool {
+	return len(i.Name) != 0
+}
+`,
+			otherCache: true,
+			err:        true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := test.ParsePackage(tc.src)
+			local := types.NewPackage("example.com/local", "local")
+			local.Scope().Insert(types.NewConst(token.NoPos, local, "Running", types.Typ[types.Int], nil))
+			c := packages.NewCache(p)
+			mc := c
+			if tc.otherCache {
+				mc = packages.NewCache(test.ParsePackage(tc.src))
+			}
+			f := NewFlattener(packages.NewImports(local.Path(), local.Name()),
+				WithLocalPkg(local),
+				WithRemotePkgPath(p.PkgPath),
+				WithTypeNamePrefix("EC2"),
+				WithEnumConstants(c),
+				WithFlattenMethods(mc))
+			_, err := f.Flatten(p.Types.Scope().Lookup("Instance").Type().(*types.Named))
+			if (err != nil) != tc.err {
+				t.Fatalf("Flatten(...): unexpected error: %v", err)
+			}
+			if tc.err {
+				return
+			}
+			if diff := cmp.Diff(tc.want, map[string][]string(f.Methods)); diff != "" {
+				t.Errorf("Flatten(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
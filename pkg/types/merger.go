@@ -15,26 +15,96 @@
 package types
 
 import (
+	"go/token"
 	"go/types"
+	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 
 	"github.com/muvaf/typewriter/pkg/packages"
 )
 
+// ConflictStrategy decides what to do when the input types of Merger have
+// fields with the same name but different types that cannot be merged.
+type ConflictStrategy int
+
 const (
-	SectionMerged = "merged"
-	SectionTypes  = "types"
+	// ConflictError returns an error.
+	ConflictError ConflictStrategy = iota
+	// ConflictPreferFirst uses the field of the first input type that has it.
+	ConflictPreferFirst
+	// ConflictPreferLast uses the field of the last input type that has it.
+	ConflictPreferLast
+	// ConflictRename keeps the field of the first input type and adds the
+	// others with a suffix derived from the name of their type, i.e. IDV2.
+	ConflictRename
 )
 
+// AggregateNameFn returns the name of the aggregate type of given type names.
+type AggregateNameFn func(names []string) string
+
+// DefaultAggregateName drops the version suffix shared by the names and adds
+// "All", i.e. BelongingAll for BelongingV1 and BelongingV2.
+func DefaultAggregateName(names []string) string {
+	base := commonBase(names)
+	if base == "" {
+		base = strings.Join(names, "")
+	}
+	return base + "All"
+}
+
 // TODO(muvaf): Using the result of union operation as ignore func parameter
 // could be helpful. Consider providing functions to make this easy. For example,
 // `ignore all fields in this type that already exists in that other type.`
 
-func NewMerger(name *types.TypeName, inputTypes []*types.Named) *Merger {
+// WithConflictStrategy sets the strategy used for fields with the same name
+// but different types. Fields whose types are named structs, or slices, maps
+// and pointers of them, are always merged recursively into a new aggregate type.
+func WithConflictStrategy(s ConflictStrategy) MergerOption {
+	return func(m *Merger) {
+		m.ConflictStrategy = s
+	}
+}
+
+// WithAggregateNameFn sets the function used to name the aggregate types
+// generated for the nested types.
+func WithAggregateNameFn(fn AggregateNameFn) MergerOption {
+	return func(m *Merger) {
+		m.AggregateNameFn = fn
+	}
+}
+
+// WithMergeOrder sets the order of the fields of the aggregate types. By
+// default, the fields of the first input type come first and the new fields of
+// the following input types are appended. OrderMarker has the same effect here
+// since the order markers are honored by Printer.
+func WithMergeOrder(o FieldOrder) MergerOption {
+	return func(m *Merger) {
+		m.FieldOrder = o
+	}
+}
+
+// WithMergeComments makes the merger carry the comments of the input types and
+// their fields to the aggregate types. The markers that point to the types the
+// inputs are aggregate of are always stripped.
+func WithMergeComments(ct *CommentTransfer) MergerOption {
+	return func(m *Merger) {
+		m.CommentTransfer = ct
+	}
+}
+
+type MergerOption func(*Merger)
+
+func NewMerger(name *types.TypeName, inputTypes []*types.Named, opts ...MergerOption) *Merger {
 	r := &Merger{
-		typeName:   name,
-		inputTypes: inputTypes,
+		typeName:        name,
+		inputTypes:      inputTypes,
+		AggregateNameFn: DefaultAggregateName,
+		Comments:        Comments{},
+	}
+	for _, f := range opts {
+		f(r)
 	}
 	return r
 }
@@ -42,42 +112,370 @@ func NewMerger(name *types.TypeName, inputTypes []*types.Named) *Merger {
 type Merger struct {
 	inputTypes []*types.Named
 	typeName   *types.TypeName
-}
 
-// TODO(muvaf): Comments are not transferred.
+	ConflictStrategy ConflictStrategy
+	AggregateNameFn  AggregateNameFn
+	FieldOrder       FieldOrder
+	CommentTransfer  *CommentTransfer
+
+	// Comments holds the comments of the generated types, which include the
+	// markers that point to the types they are aggregate of.
+	Comments Comments
+}
 
 func (m *Merger) Generate() (*types.Named, packages.CommentMarkers, error) {
-	varMap := map[string]*types.Var{}
-	tagMap := map[string]string{}
-	cm := packages.NewCommentMarkers("")
-	for _, c := range m.inputTypes {
-		addMergedTypeMarker(cm, c)
+	n, err := m.merge(map[string]*types.Named{}, m.typeName, m.inputTypes)
+	if err != nil {
+		return nil, packages.CommentMarkers{}, err
+	}
+	return n, aggregatedMarkers(m.inputTypes), nil
+}
+
+type candidate struct {
+	index int
+	field *types.Var
+	tag   string
+}
+
+// merge returns a new type with the given name that has the union of the fields
+// of the input types. The aggregates of nested types are stored in given map
+// indexed by their input types so that they're generated only once.
+func (m *Merger) merge(aggregates map[string]*types.Named, name *types.TypeName, inputTypes []*types.Named) (*types.Named, error) {
+	// The type is registered before its fields are merged so that the nested
+	// types referring back to it use the same aggregate.
+	n := types.NewNamed(name, nil, nil)
+	aggregates[aggregateKey(inputTypes)] = n
+	comment, err := m.typeComment(inputTypes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get comment of type %s", name.Name())
+	}
+	m.Comments.AddTypeComment(name, comment)
+
+	var names []string
+	candidates := map[string][]candidate{}
+	for i, c := range inputTypes {
 		in, ok := c.Underlying().(*types.Struct)
 		if !ok {
-			return nil, packages.CommentMarkers{}, errors.New("merger cannot work with enum types")
+			return nil, errors.New("merger cannot work with enum types")
 		}
-		for i := 0; i < in.NumFields(); i++ {
-			varMap[in.Field(i).Name()] = in.Field(i)
-			tagMap[in.Field(i).Name()] = in.Tag(i)
+		for j := 0; j < in.NumFields(); j++ {
+			f := in.Field(j)
+			if _, ok := candidates[f.Name()]; !ok {
+				names = append(names, f.Name())
+			}
+			candidates[f.Name()] = append(candidates[f.Name()], candidate{index: i, field: f, tag: in.Tag(j)})
 		}
 	}
-	fields := make([]*types.Var, len(varMap))
-	tags := make([]string, len(varMap))
-	i := 0
-	for name, v := range varMap {
-		fields[i] = v
-		if t, ok := tagMap[name]; ok {
-			tags[i] = t
+	// Renamed fields must not clash with the fields of the input types or
+	// other renamed fields.
+	taken := make(map[string]struct{}, len(names))
+	for _, fieldName := range names {
+		taken[fieldName] = struct{}{}
+	}
+	var fields []*types.Var
+	var tags []string
+	for _, fieldName := range names {
+		resolved, err := m.resolve(aggregates, name.Pkg(), inputTypes, candidates[fieldName], taken)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot merge field %s of type %s", fieldName, name.Name())
+		}
+		for _, c := range resolved {
+			fields = append(fields, c.field)
+			tags = append(tags, c.tag)
 		}
-		i++
+		if err := m.addFieldComments(name, candidates[fieldName], resolved); err != nil {
+			return nil, errors.Wrapf(err, "cannot get comment of field %s of type %s", fieldName, name.Name())
+		}
+	}
+	fields, tags = SortFields(fields, tags, m.FieldOrder, packages.CommentMarkers{})
+	n.SetUnderlying(types.NewStruct(fields, tags))
+	return n, nil
+}
+
+// typeComment returns the comment of the aggregate of given types, which ends
+// with the markers that point to them.
+func (m *Merger) typeComment(inputTypes []*types.Named) (string, error) {
+	markers := strings.TrimPrefix(aggregatedMarkers(inputTypes).Print(packages.CommentPrefix), "\n")
+	if m.CommentTransfer == nil {
+		return markers, nil
+	}
+	objs := make([]types.Object, len(inputTypes))
+	for i, t := range inputTypes {
+		objs[i] = t.Obj()
+	}
+	doc, err := m.CommentTransfer.without(packages.SectionTypes, packages.TypesAggregated).Comment(objs...)
+	if err != nil || doc == "" {
+		return markers, err
 	}
-	n := types.NewNamed(m.typeName, types.NewStruct(fields, tags), nil)
-	return n, cm, nil
+	return doc + "\n" + markers, nil
 }
 
-func addMergedTypeMarker(cm packages.CommentMarkers, n *types.Named) {
-	if _, ok := cm.SectionContents[SectionTypes]; !ok {
-		cm.SectionContents[SectionTypes] = map[string]string{}
+// addFieldComments adds the comments of the resolved fields. A field that
+// replaces all candidates gets their merged comment while renamed fields keep
+// their own.
+func (m *Merger) addFieldComments(name *types.TypeName, cs, resolved []candidate) error {
+	if m.CommentTransfer == nil {
+		return nil
+	}
+	for _, r := range resolved {
+		objs := []types.Object{r.field}
+		if len(resolved) == 1 {
+			objs = make([]types.Object, len(cs))
+			for i, c := range cs {
+				objs[i] = c.field
+			}
+		}
+		comment, err := m.CommentTransfer.Comment(objs...)
+		if err != nil {
+			return err
+		}
+		if comment != "" {
+			m.Comments.AddFieldComment(name, r.field.Name(), comment)
+		}
+	}
+	return nil
+}
+
+// resolve returns the fields that should exist in the aggregate type for the
+// given fields with the same name. The names of the renamed fields are added
+// to taken.
+func (m *Merger) resolve(aggregates map[string]*types.Named, pkg *types.Package, inputTypes []*types.Named, cs []candidate, taken map[string]struct{}) ([]candidate, error) {
+	identical := true
+	for _, c := range cs[1:] {
+		if !types.Identical(cs[0].field.Type(), c.field.Type()) {
+			identical = false
+			break
+		}
+	}
+	if identical {
+		return cs[:1], nil
+	}
+	fieldTypes := make([]types.Type, len(cs))
+	for i, c := range cs {
+		fieldTypes[i] = c.field.Type()
+	}
+	if nested, rebuild, ok := nestedNamed(fieldTypes); ok {
+		aggregate, err := m.aggregate(aggregates, pkg, nested)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot generate aggregate of nested types")
+		}
+		f := cs[0].field
+		return []candidate{{
+			index: cs[0].index,
+			field: types.NewField(f.Pos(), f.Pkg(), f.Name(), rebuild(aggregate), f.Embedded()),
+			tag:   cs[0].tag,
+		}}, nil
+	}
+	switch m.ConflictStrategy {
+	case ConflictPreferFirst:
+		return cs[:1], nil
+	case ConflictPreferLast:
+		return cs[len(cs)-1:], nil
+	case ConflictRename:
+		return m.rename(inputTypes, cs, taken), nil
+	default:
+		return nil, errors.Errorf("conflicting types %s in %s and %s in %s",
+			cs[0].field.Type().String(), inputTypes[cs[0].index].Obj().Name(),
+			cs[1].field.Type().String(), inputTypes[cs[1].index].Obj().Name())
+	}
+}
+
+// rename keeps the first field and adds a renamed copy for every field whose
+// type differs from the ones that are already kept. A number is added to the
+// new name if it's taken.
+func (m *Merger) rename(inputTypes []*types.Named, cs []candidate, taken map[string]struct{}) []candidate {
+	isTaken := func(name string) bool {
+		_, ok := taken[name]
+		return ok
+	}
+	names := make([]string, len(inputTypes))
+	for i, t := range inputTypes {
+		names[i] = t.Obj().Name()
+	}
+	base := commonBase(names)
+	result := cs[:1]
+	for _, c := range cs[1:] {
+		kept := false
+		for _, r := range result {
+			if types.Identical(r.field.Type(), c.field.Type()) {
+				kept = true
+				break
+			}
+		}
+		if kept {
+			continue
+		}
+		suffix := strings.TrimPrefix(names[c.index], base)
+		if suffix == "" {
+			suffix = strconv.Itoa(c.index)
+		}
+		f := c.field
+		name := uniqueName(f.Name()+suffix, isTaken)
+		taken[name] = struct{}{}
+		result = append(result, candidate{
+			index: c.index,
+			field: types.NewField(f.Pos(), f.Pkg(), name, f.Type(), false),
+			tag:   c.tag,
+		})
+	}
+	return result
+}
+
+// aggregate returns the aggregate type of given named types, generating it if
+// it doesn't exist yet.
+func (m *Merger) aggregate(aggregates map[string]*types.Named, pkg *types.Package, inputTypes []*types.Named) (*types.Named, error) {
+	if n, ok := aggregates[aggregateKey(inputTypes)]; ok {
+		return n, nil
+	}
+	names := make([]string, len(inputTypes))
+	for i, t := range inputTypes {
+		names[i] = t.Obj().Name()
+	}
+	name := types.NewTypeName(token.NoPos, pkg, m.AggregateNameFn(names), nil)
+	return m.merge(aggregates, name, inputTypes)
+}
+
+// nestedNamed returns the distinct named struct types at the core of given types
+// if they all have the same shape, like []*BelongingV1 and []*BelongingV2,
+// together with a function that builds the same shape around another type.
+func nestedNamed(ts []types.Type) ([]*types.Named, func(types.Type) types.Type, bool) {
+	switch first := ts[0].(type) {
+	case *types.Named:
+		if _, ok := first.Underlying().(*types.Struct); !ok {
+			return nil, nil, false
+		}
+		var result []*types.Named
+		for _, t := range ts {
+			n, ok := t.(*types.Named)
+			if !ok {
+				return nil, nil, false
+			}
+			if _, ok := n.Underlying().(*types.Struct); !ok {
+				return nil, nil, false
+			}
+			seen := false
+			for _, r := range result {
+				if types.Identical(r, n) {
+					seen = true
+					break
+				}
+			}
+			if !seen {
+				result = append(result, n)
+			}
+		}
+		return result, func(t types.Type) types.Type { return t }, true
+	case *types.Pointer, *types.Slice, *types.Array, *types.Map:
+		elems := make([]types.Type, len(ts))
+		for i, t := range ts {
+			e, ok := sameShapeElem(first, t)
+			if !ok {
+				return nil, nil, false
+			}
+			elems[i] = e
+		}
+		nested, rebuild, ok := nestedNamed(elems)
+		if !ok {
+			return nil, nil, false
+		}
+		return nested, func(t types.Type) types.Type { return wrapLike(first, rebuild(t)) }, true
+	}
+	return nil, nil, false
+}
+
+// sameShapeElem returns the element type of t if it has the same kind of
+// container type as the reference.
+func sameShapeElem(ref, t types.Type) (types.Type, bool) {
+	switch r := ref.(type) {
+	case *types.Pointer:
+		p, ok := t.(*types.Pointer)
+		if !ok {
+			return nil, false
+		}
+		return p.Elem(), true
+	case *types.Slice:
+		s, ok := t.(*types.Slice)
+		if !ok {
+			return nil, false
+		}
+		return s.Elem(), true
+	case *types.Array:
+		a, ok := t.(*types.Array)
+		if !ok || a.Len() != r.Len() {
+			return nil, false
+		}
+		return a.Elem(), true
+	case *types.Map:
+		mt, ok := t.(*types.Map)
+		if !ok || !types.Identical(mt.Key(), r.Key()) {
+			return nil, false
+		}
+		return mt.Elem(), true
+	}
+	return nil, false
+}
+
+// wrapLike returns a container type of the same kind as the reference with the
+// given element type.
+func wrapLike(ref, elem types.Type) types.Type {
+	switch r := ref.(type) {
+	case *types.Pointer:
+		return types.NewPointer(elem)
+	case *types.Slice:
+		return types.NewSlice(elem)
+	case *types.Array:
+		return types.NewArray(elem, r.Len())
+	case *types.Map:
+		return types.NewMap(r.Key(), elem)
+	}
+	return elem
+}
+
+func aggregateKey(ts []*types.Named) string {
+	paths := make([]string, len(ts))
+	for i, t := range ts {
+		paths[i] = QualifiedTypePath(t.Obj())
+	}
+	return strings.Join(paths, ",")
+}
+
+// commonBase returns the longest common prefix of the names without the
+// version marker they share, i.e. "User" for UserV1 and UserV2.
+func commonBase(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	prefix := names[0]
+	for _, n := range names[1:] {
+		i := 0
+		for i < len(prefix) && i < len(n) && prefix[i] == n[i] {
+			i++
+		}
+		prefix = prefix[:i]
+	}
+	// The prefix may include a part of the version, like "Userv1" for Userv1
+	// and Userv1beta1, so the digits are dropped before the version marker.
+	j := len(prefix)
+	for j > 0 && prefix[j-1] >= '0' && prefix[j-1] <= '9' {
+		j--
+	}
+	if j == 0 || (prefix[j-1] != 'V' && prefix[j-1] != 'v') {
+		return prefix
+	}
+	for _, n := range names {
+		if len(n) == j || n[j] < '0' || n[j] > '9' {
+			return prefix
+		}
+	}
+	return prefix[:j-1]
+}
+
+// aggregatedMarkers returns the markers that point to the given types so that
+// the producers of them can be generated from the aggregate type.
+func aggregatedMarkers(inputTypes []*types.Named) packages.CommentMarkers {
+	cm := packages.NewCommentMarkers("")
+	for _, n := range inputTypes {
+		cm.Add(packages.SectionTypes, packages.TypesAggregated, QualifiedTypePath(n.Obj()))
 	}
-	cm.SectionContents[SectionTypes][SectionMerged] = QualifiedTypePath(n.Obj())
+	return cm
 }
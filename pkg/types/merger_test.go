@@ -0,0 +1,232 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+	"github.com/muvaf/typewriter/pkg/test"
+)
+
+const versionedTypes = `
+package test
+
+type UserV1 struct {
+	Name       string
+	ID         int
+	Belongings []BelongingV1
+	Owner      *BelongingV1
+}
+
+type BelongingV1 struct {
+	Automobiles []string
+}
+
+type UserV2 struct {
+	Name       string
+	ID         string
+	Belongings []BelongingV2
+	Owner      *BelongingV2
+}
+
+type BelongingV2 struct {
+	Cars []string
+}
+`
+
+// fieldList returns the fields of the struct in "<name> <type>" format with
+// the types qualified by package name.
+func fieldList(n *types.Named) []string {
+	s := n.Underlying().(*types.Struct)
+	result := make([]string, s.NumFields())
+	for i := 0; i < s.NumFields(); i++ {
+		result[i] = s.Field(i).Name() + " " + types.TypeString(s.Field(i).Type(), func(p *types.Package) string { return p.Name() })
+	}
+	return result
+}
+
+func TestMergerGenerate(t *testing.T) {
+	s := test.ParseString(versionedTypes)
+	inputs := []*types.Named{s.Lookup("UserV1").Type().(*types.Named), s.Lookup("UserV2").Type().(*types.Named)}
+	type want struct {
+		fields          []string
+		aggregateFields []string
+		err             bool
+	}
+	cases := map[string]struct {
+		strategy ConflictStrategy
+		want
+	}{
+		"Error": {
+			strategy: ConflictError,
+			want:     want{err: true},
+		},
+		"PreferFirst": {
+			strategy: ConflictPreferFirst,
+			want: want{
+				fields:          []string{"Name string", "ID int", "Belongings []test.BelongingAll", "Owner *test.BelongingAll"},
+				aggregateFields: []string{"Automobiles []string", "Cars []string"},
+			},
+		},
+		"PreferLast": {
+			strategy: ConflictPreferLast,
+			want: want{
+				fields:          []string{"Name string", "ID string", "Belongings []test.BelongingAll", "Owner *test.BelongingAll"},
+				aggregateFields: []string{"Automobiles []string", "Cars []string"},
+			},
+		},
+		"Rename": {
+			strategy: ConflictRename,
+			want: want{
+				fields:          []string{"Name string", "ID int", "IDV2 string", "Belongings []test.BelongingAll", "Owner *test.BelongingAll"},
+				aggregateFields: []string{"Automobiles []string", "Cars []string"},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			pkg := types.NewPackage("test", "test")
+			m := NewMerger(types.NewTypeName(token.NoPos, pkg, "UserAll", nil), inputs, WithConflictStrategy(tc.strategy))
+			n, _, err := m.Generate()
+			if (err != nil) != tc.want.err {
+				t.Fatalf("Generate(): unexpected error: %v", err)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want.fields, fieldList(n)); diff != "" {
+				t.Errorf("Generate(): -want, +got:\n%s", diff)
+			}
+			belongings := n.Underlying().(*types.Struct).Field(len(tc.want.fields) - 1).Type().(*types.Pointer).Elem().(*types.Named)
+			if diff := cmp.Diff(tc.want.aggregateFields, fieldList(belongings)); diff != "" {
+				t.Errorf("Generate(): aggregate: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMergerRenameCollision(t *testing.T) {
+	s := test.ParseString(`
+package test
+
+type UserV1 struct {
+	ID   int
+	IDV2 bool
+}
+
+type UserV2 struct {
+	ID string
+}
+
+type UserV3 struct {
+	ID []byte
+}
+`)
+	inputs := []*types.Named{s.Lookup("UserV1").Type().(*types.Named), s.Lookup("UserV2").Type().(*types.Named), s.Lookup("UserV3").Type().(*types.Named)}
+	m := NewMerger(types.NewTypeName(token.NoPos, types.NewPackage("test", "test"), "UserAll", nil), inputs, WithConflictStrategy(ConflictRename))
+	n, _, err := m.Generate()
+	if err != nil {
+		t.Fatalf("Generate(): unexpected error: %s", err)
+	}
+	want := []string{"ID int", "IDV22 string", "IDV3 []byte", "IDV2 bool"}
+	if diff := cmp.Diff(want, fieldList(n)); diff != "" {
+		t.Errorf("Generate(): -want, +got:\n%s", diff)
+	}
+}
+
+func TestDefaultAggregateName(t *testing.T) {
+	cases := map[string]struct {
+		names []string
+		want  string
+	}{
+		"Versions":        {names: []string{"UserV1", "UserV2"}, want: "UserAll"},
+		"LowerVersions":   {names: []string{"Userv1", "Userv1beta1"}, want: "UserAll"},
+		"NoVersion":       {names: []string{"Vehicle", "VehicleOld"}, want: "VehicleAll"},
+		"EndsWithV":       {names: []string{"DevA", "DevB"}, want: "DevAll"},
+		"NothingInCommon": {names: []string{"Car", "Automobile"}, want: "CarAutomobileAll"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, DefaultAggregateName(tc.names)); diff != "" {
+				t.Errorf("DefaultAggregateName(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+const documentedTypes = `
+package test
+
+// UserV1 is a user.
+// +typewriter:types:aggregated=test.UserV0
+// +typewriter:types:order=Name
+type UserV1 struct {
+	// Name of the user.
+	Name string
+}
+
+// UserV2 is a user with a group.
+type UserV2 struct {
+	// Name of the user.
+	Name string
+
+	// Group of the user.
+	// +typewriter:field:default="users"
+	Group string
+}
+`
+
+func TestMergerComments(t *testing.T) {
+	p := test.ParsePackage(documentedTypes)
+	cc := packages.NewCommentCache(packages.NewCache(p))
+	inputs := []*types.Named{p.Types.Scope().Lookup("UserV1").Type().(*types.Named), p.Types.Scope().Lookup("UserV2").Type().(*types.Named)}
+	cases := map[string]struct {
+		opts []CommentTransferOption
+		want Comments
+	}{
+		"Default": {
+			want: Comments{
+				"test.UserAll":       "// UserV1 is a user.\n// +typewriter:types:order=Name\n// +typewriter:types:aggregated=simple.go.UserV1\n// +typewriter:types:aggregated=simple.go.UserV2",
+				"test.UserAll:Name":  "// Name of the user.",
+				"test.UserAll:Group": "// Group of the user.\n// +typewriter:field:default=\"users\"",
+			},
+		},
+		"JoinAndStrip": {
+			opts: []CommentTransferOption{WithCommentMergeStrategy(CommentMergeJoin), WithMarkerFilter(StripMarkers("field:default"))},
+			want: Comments{
+				"test.UserAll":       "// UserV1 is a user.\n// +typewriter:types:order=Name\n//\n// UserV2 is a user with a group.\n// +typewriter:types:aggregated=simple.go.UserV1\n// +typewriter:types:aggregated=simple.go.UserV2",
+				"test.UserAll:Name":  "// Name of the user.",
+				"test.UserAll:Group": "// Group of the user.",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			pkg := types.NewPackage("test", "test")
+			m := NewMerger(types.NewTypeName(token.NoPos, pkg, "UserAll", nil), inputs, WithMergeComments(NewCommentTransfer(cc, tc.opts...)))
+			if _, _, err := m.Generate(); err != nil {
+				t.Fatalf("Generate(): unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, m.Comments); diff != "" {
+				t.Errorf("Generate(): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
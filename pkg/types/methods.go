@@ -0,0 +1,271 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Methods holds the source code of the methods of the named types indexed by
+// the full paths of the types in <package path>.<type name> format.
+type Methods map[string][]string
+
+// edit is the replacement of the text between the offsets.
+type edit struct {
+	start, end int
+	text       string
+}
+
+// copyMethods adds the methods of the source type to the Methods of the
+// flattener. The receivers and the references to the copied types and
+// constants are replaced with the local ones and the other package-level
+// identifiers of the remote package are qualified with its import alias.
+func (f *Flattener) copyMethods(src *types.Named, copies map[*types.TypeName]*types.Named) error {
+	p, err := f.MethodCache.GetPackage(src.Obj().Pkg().Path())
+	if err != nil {
+		return errors.Wrapf(err, "cannot get package %s", src.Obj().Pkg().Path())
+	}
+	if p.TypesInfo == nil {
+		return errors.Errorf("package %s is loaded without type information", p.PkgPath)
+	}
+	if p.Types.Scope().Lookup(src.Obj().Name()) != src.Origin().Obj() {
+		return errors.Errorf("type %s is not loaded from the method cache", QualifiedTypePath(src.Obj()))
+	}
+	for _, file := range p.Syntax {
+		for _, decl := range file.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok || fd.Recv == nil || len(fd.Recv.List) == 0 {
+				continue
+			}
+			if receiverName(fd.Recv.List[0].Type) != src.Obj().Name() {
+				continue
+			}
+			m, err := f.copyMethod(p.Types, p.Fset, file, p.TypesInfo, fd, copies)
+			if err != nil {
+				return errors.Wrapf(err, "cannot copy method %s", fd.Name.Name)
+			}
+			local := copies[src.Obj()].Obj()
+			f.Methods[QualifiedTypePath(local)] = append(f.Methods[QualifiedTypePath(local)], m)
+		}
+	}
+	return nil
+}
+
+// copyMethod returns the source code of the method with the identifiers
+// rewritten. Since the source of the file may not be available, the method is
+// printed and parsed again to find the offsets of the identifiers, which are
+// in the same order in both trees.
+func (f *Flattener) copyMethod(remote *types.Package, fset *token.FileSet, file *ast.File, info *types.Info, fd *ast.FuncDecl, copies map[*types.TypeName]*types.Named) (string, error) {
+	start := fd.Pos()
+	if fd.Doc != nil {
+		start = fd.Doc.Pos()
+	}
+	var comments []*ast.CommentGroup
+	for _, cg := range file.Comments {
+		if cg.Pos() >= start && cg.End() <= fd.End() {
+			comments = append(comments, cg)
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := printer.Fprint(buf, fset, &printer.CommentedNode{Node: fd, Comments: comments}); err != nil {
+		return "", errors.Wrap(err, "cannot print method")
+	}
+	const header = "package p\n\n"
+	src := header + buf.String()
+	pfset := token.NewFileSet()
+	pf, err := parser.ParseFile(pfset, "", src, parser.ParseComments)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot parse printed method")
+	}
+	original := identsOf(fd)
+	printed := identsOf(pf.Decls[0])
+	if len(original) != len(printed) {
+		return "", errors.New("printed method does not match the original")
+	}
+	// The package names are removed together with the dot when the package is
+	// the local one.
+	selected := map[*ast.Ident]*ast.SelectorExpr{}
+	ast.Inspect(pf.Decls[0], func(n ast.Node) bool {
+		if se, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := se.X.(*ast.Ident); ok {
+				selected[id] = se
+			}
+		}
+		return true
+	})
+	var edits []edit
+	for i, id := range original {
+		obj := info.Uses[id]
+		if obj == nil {
+			continue
+		}
+		text, err := f.localIdent(remote, obj, copies)
+		if err != nil {
+			return "", err
+		}
+		if text == id.Name {
+			continue
+		}
+		pid := printed[i]
+		e := edit{start: pfset.Position(pid.Pos()).Offset, end: pfset.Position(pid.End()).Offset, text: text}
+		if se, ok := selected[pid]; ok && text == "" {
+			e.end = pfset.Position(se.Sel.Pos()).Offset
+		}
+		edits = append(edits, e)
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].start < edits[j].start
+	})
+	out := &strings.Builder{}
+	last := len(header)
+	for _, e := range edits {
+		out.WriteString(src[last:e.start])
+		out.WriteString(e.text)
+		last = e.end
+	}
+	out.WriteString(src[last:])
+	return out.String(), nil
+}
+
+// localIdent returns the identifier that should be used in the local package
+// to refer to the given object used in a method of a remote type. It returns
+// an error if the object is not accessible from the local package.
+func (f *Flattener) localIdent(remote *types.Package, obj types.Object, copies map[*types.TypeName]*types.Named) (string, error) {
+	switch o := obj.(type) {
+	case *types.PkgName:
+		return f.Imports.Qualifier()(o.Imported()), nil
+	case *types.TypeName:
+		if n, ok := copies[o]; ok {
+			return n.Obj().Name(), nil
+		}
+	case *types.Const:
+		if name, ok := f.constNames[o]; ok {
+			return name, nil
+		}
+	case *types.Var:
+		if o.IsField() && !o.Exported() && !f.hasCopiedField(o, copies) {
+			return "", errors.Errorf("field %s of package %s is unexported and not copied", o.Name(), o.Pkg().Path())
+		}
+		if o.IsField() {
+			return obj.Name(), nil
+		}
+	case *types.Func:
+		if sig, ok := o.Type().(*types.Signature); ok && sig.Recv() != nil {
+			if !o.Exported() && !isCopiedReceiver(sig.Recv().Type(), copies) {
+				return "", errors.Errorf("method %s of package %s is unexported and not copied", o.Name(), o.Pkg().Path())
+			}
+			return obj.Name(), nil
+		}
+	}
+	// Only the package-level objects of the remote package need to be
+	// qualified. The ones of other packages are selected from their packages
+	// already.
+	if obj.Pkg() != remote || obj.Parent() != remote.Scope() {
+		return obj.Name(), nil
+	}
+	if !obj.Exported() {
+		return "", errors.Errorf("%s of package %s is unexported and not copied", obj.Name(), obj.Pkg().Path())
+	}
+	return f.Imports.ObjectString(obj), nil
+}
+
+// hasCopiedField returns true if the field belongs to one of the copied struct
+// types and exists in its copy.
+func (f *Flattener) hasCopiedField(v *types.Var, copies map[*types.TypeName]*types.Named) bool {
+	for src, c := range copies {
+		s, ok := src.Type().Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		for i := 0; i < s.NumFields(); i++ {
+			if s.Field(i) != v {
+				continue
+			}
+			cs, ok := c.Underlying().(*types.Struct)
+			if !ok {
+				return false
+			}
+			for j := 0; j < cs.NumFields(); j++ {
+				if cs.Field(j).Name() == v.Name() {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return false
+}
+
+// isCopiedReceiver returns true if the receiver type is one of the copied
+// types, whose methods are copied as well.
+func isCopiedReceiver(t types.Type, copies map[*types.TypeName]*types.Named) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	n, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	_, ok = copies[n.Origin().Obj()]
+	return ok
+}
+
+// receiverName returns the name of the type in the receiver expression, i.e.
+// "T" for "*T[K]".
+func receiverName(e ast.Expr) string {
+	switch x := e.(type) {
+	case *ast.StarExpr:
+		return receiverName(x.X)
+	case *ast.ParenExpr:
+		return receiverName(x.X)
+	case *ast.IndexExpr:
+		return receiverName(x.X)
+	case *ast.IndexListExpr:
+		return receiverName(x.X)
+	case *ast.Ident:
+		return x.Name
+	}
+	return ""
+}
+
+// identsOf returns the identifiers in the node in the order they're visited.
+func identsOf(n ast.Node) []*ast.Ident {
+	var result []*ast.Ident
+	ast.Inspect(n, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			result = append(result, id)
+		}
+		return true
+	})
+	return result
+}
+
+// printMethods returns the methods of the type.
+func (tp *Printer) printMethods(n *types.Named) string {
+	out := ""
+	for _, m := range tp.Methods[QualifiedTypePath(n.Obj())] {
+		out += "\n\n" + m
+	}
+	return out
+}
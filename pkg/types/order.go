@@ -0,0 +1,90 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+)
+
+// FieldOrder decides the order of the fields of the generated types.
+type FieldOrder int
+
+const (
+	// OrderDeclaration keeps the fields in the order they are declared. Merger
+	// puts the fields of the first input type first and appends the new ones
+	// of the following input types.
+	OrderDeclaration FieldOrder = iota
+	// OrderAlphabetical sorts the fields by their names.
+	OrderAlphabetical
+	// OrderMarker puts the fields listed in the order marker of the type, i.e.
+	// +typewriter:types:order=Name,Surname first in the given order and the
+	// rest in the order they are declared.
+	OrderMarker
+)
+
+// SortFields returns the fields and their tags in given order. The markers
+// are used only for OrderMarker.
+func SortFields(fields []*types.Var, tags []string, o FieldOrder, cm packages.CommentMarkers) ([]*types.Var, []string) {
+	rank := map[string]int{}
+	switch o {
+	case OrderAlphabetical:
+		names := make([]string, len(fields))
+		for i, f := range fields {
+			names[i] = f.Name()
+		}
+		sort.Strings(names)
+		for i, n := range names {
+			rank[n] = i
+		}
+	case OrderMarker:
+		for _, v := range cm.Values(packages.SectionTypes, packages.TypesFieldOrder) {
+			for _, n := range strings.Split(v, ",") {
+				n = strings.TrimSpace(n)
+				if _, ok := rank[n]; !ok && n != "" {
+					rank[n] = len(rank)
+				}
+			}
+		}
+	default:
+		return fields, tags
+	}
+	idx := make([]int, len(fields))
+	for i := range idx {
+		idx[i] = i
+	}
+	// Fields without a rank are placed after the ranked ones and stable sort
+	// keeps them in declaration order.
+	sort.SliceStable(idx, func(i, j int) bool {
+		ri, iok := rank[fields[idx[i]].Name()]
+		rj, jok := rank[fields[idx[j]].Name()]
+		if iok != jok {
+			return iok
+		}
+		return ri < rj
+	})
+	sortedFields := make([]*types.Var, len(fields))
+	sortedTags := make([]string, len(fields))
+	for i, j := range idx {
+		sortedFields[i] = fields[j]
+		if j < len(tags) {
+			sortedTags[i] = tags[j]
+		}
+	}
+	return sortedFields, sortedTags
+}
@@ -0,0 +1,90 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+	"github.com/muvaf/typewriter/pkg/test"
+)
+
+func TestSortFields(t *testing.T) {
+	s := test.ParseString(`
+package test
+
+type User struct {
+	Name    string ` + "`json:\"name\"`" + `
+	Surname string ` + "`json:\"surname\"`" + `
+	ID      int    ` + "`json:\"id\"`" + `
+	Age     int    ` + "`json:\"age\"`" + `
+}
+`)
+	st := s.Lookup("User").Type().Underlying().(*types.Struct)
+	fields := make([]*types.Var, st.NumFields())
+	tags := make([]string, st.NumFields())
+	for i := 0; i < st.NumFields(); i++ {
+		fields[i] = st.Field(i)
+		tags[i] = st.Tag(i)
+	}
+	type want struct {
+		names []string
+		tags  []string
+	}
+	cases := map[string]struct {
+		order   FieldOrder
+		comment string
+		want
+	}{
+		"Declaration": {
+			order: OrderDeclaration,
+			want: want{
+				names: []string{"Name", "Surname", "ID", "Age"},
+				tags:  []string{`json:"name"`, `json:"surname"`, `json:"id"`, `json:"age"`},
+			},
+		},
+		"Alphabetical": {
+			order: OrderAlphabetical,
+			want: want{
+				names: []string{"Age", "ID", "Name", "Surname"},
+				tags:  []string{`json:"age"`, `json:"id"`, `json:"name"`, `json:"surname"`},
+			},
+		},
+		"Marker": {
+			order:   OrderMarker,
+			comment: "// +typewriter:types:order=ID, Age\n// +typewriter:types:order=Missing",
+			want: want{
+				names: []string{"ID", "Age", "Name", "Surname"},
+				tags:  []string{`json:"id"`, `json:"age"`, `json:"name"`, `json:"surname"`},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cm := packages.NewCommentMarkersFromText(tc.comment, packages.CommentPrefix)
+			gotFields, gotTags := SortFields(fields, tags, tc.order, cm)
+			got := want{tags: gotTags}
+			for _, f := range gotFields {
+				got.names = append(got.names, f.Name())
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("SortFields(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -16,7 +16,7 @@ package types
 
 import (
 	"bytes"
-	"fmt"
+	"go/token"
 	"go/types"
 	"sort"
 	"strings"
@@ -36,20 +36,35 @@ const (
 	StructTypeTmpl = `
 
 {{ .Comment }}
-type {{ .Name }} struct {
+type {{ .Name }}{{ .TypeParams }} struct {
 {{ .Fields }}
 }`
-	FieldTmpl    = "\n\n\n{{ .Comment }}\n{{ .Name }} {{ .Type }} `{{ .Tag }}`"
+	FieldTmpl    = "\n\n\n{{ .Comment }}\n{{ .Name }} {{ .Type }}{{ if .Tag }} `{{ .Tag }}`{{ end }}"
 	EnumTypeTmpl = `
 
 {{ .Comment }}
-type {{ .Name }} {{ .UnderlyingType }}`
+type {{ .Name }}{{ .TypeParams }} {{ .UnderlyingType }}`
 )
 
+// TypeTmplData is the data about the type that is available to all templates
+// in addition to what's printed.
+type TypeTmplData struct {
+	// Markers are the markers in the comment of the type.
+	Markers packages.CommentMarkers
+	// SourcePackage is the path of the package that the type is copied from,
+	// or its own package if it's not a copy.
+	SourcePackage string
+	// OriginalName is the name of the type that the type is copied from, or its
+	// own name if it's not a copy.
+	OriginalName string
+}
+
 type StructTypeTmplInput struct {
-	Name    string
-	Fields  string
-	Comment string
+	TypeTmplData
+	Name       string
+	TypeParams string
+	Fields     string
+	Comment    string
 }
 
 type FieldTmplInput struct {
@@ -57,27 +72,125 @@ type FieldTmplInput struct {
 	Type    string
 	Tag     string
 	Comment string
+	// Markers are the markers in the comment of the field.
+	Markers packages.CommentMarkers
 }
 
 type EnumTypeTmplInput struct {
+	TypeTmplData
 	Name           string
+	TypeParams     string
 	UnderlyingType string
 	Comment        string
 }
 
+// Sources holds the remote types that the local types are copied from indexed
+// by the full paths of the local types in <package path>.<type name> format.
+type Sources map[string]*types.TypeName
+
 func WithComments(c Comments) PrinterOption {
 	return func(p *Printer) {
 		p.Comments = c
 	}
 }
 
+// WithFieldOrder sets the order the fields of struct types are printed in. By
+// default, they are printed in the order they are declared.
+func WithFieldOrder(o FieldOrder) PrinterOption {
+	return func(p *Printer) {
+		p.FieldOrder = o
+	}
+}
+
+// WithConstants sets the constants to be printed after the types they belong
+// to.
+func WithConstants(c Constants) PrinterOption {
+	return func(p *Printer) {
+		p.Constants = c
+	}
+}
+
+// WithMethods sets the methods to be printed after the types they belong to.
+func WithMethods(m Methods) PrinterOption {
+	return func(p *Printer) {
+		p.Methods = m
+	}
+}
+
+// WithStructTemplate sets the template used to print struct types. It's
+// executed with StructTypeTmplInput.
+func WithStructTemplate(t string) PrinterOption {
+	return func(p *Printer) {
+		p.StructTemplate = t
+	}
+}
+
+// WithFieldTemplate sets the template used to print the fields of struct
+// types. It's executed with FieldTmplInput.
+func WithFieldTemplate(t string) PrinterOption {
+	return func(p *Printer) {
+		p.FieldTemplate = t
+	}
+}
+
+// WithEnumTemplate sets the template used to print the types that are not
+// structs. It's executed with EnumTypeTmplInput.
+func WithEnumTemplate(t string) PrinterOption {
+	return func(p *Printer) {
+		p.EnumTemplate = t
+	}
+}
+
+// WithSources sets the remote types that the local types are copied from so
+// that the templates can use their information.
+func WithSources(s Sources) PrinterOption {
+	return func(p *Printer) {
+		p.Sources = s
+	}
+}
+
+// WithDeclaredTypes sets the named types that the types are printed to be
+// declared with instead of their underlying types.
+func WithDeclaredTypes(d DeclaredTypes) PrinterOption {
+	return func(p *Printer) {
+		p.Declared = d
+	}
+}
+
+// WithDuplicateStrategy sets what to do when a type with the same name but a
+// different structure exists in the target scope. By default, an error is
+// returned.
+func WithDuplicateStrategy(s DuplicateStrategy) PrinterOption {
+	return func(p *Printer) {
+		p.DuplicateStrategy = s
+	}
+}
+
+// WithOverlay sets the scope that the printed types are recorded in. Printers
+// sharing the same overlay don't print the types printed by each other.
+func WithOverlay(s *types.Scope) PrinterOption {
+	return func(p *Printer) {
+		p.Overlay = s
+	}
+}
+
 type PrinterOption func(*Printer)
 
 func NewPrinter(im *packages.Imports, targetScope *types.Scope, opts ...PrinterOption) *Printer {
 	p := &Printer{
 		Imports:     im,
 		TargetScope: targetScope,
+		Overlay:     types.NewScope(nil, token.NoPos, token.NoPos, "overlay"),
 		Comments:    Comments{},
+		Constants:   Constants{},
+		Methods:     Methods{},
+		Sources:     Sources{},
+		Declared:    DeclaredTypes{},
+		renamed:     map[*types.TypeName]*types.Named{},
+
+		StructTemplate: StructTypeTmpl,
+		FieldTemplate:  FieldTmpl,
+		EnumTemplate:   EnumTypeTmpl,
 	}
 
 	for _, f := range opts {
@@ -87,9 +200,31 @@ func NewPrinter(im *packages.Imports, targetScope *types.Scope, opts ...PrinterO
 }
 
 type Printer struct {
-	Imports     *packages.Imports
+	Imports *packages.Imports
+	// TargetScope has the existing objects of the target package. It's not
+	// changed by the printer.
 	TargetScope *types.Scope
-	Comments    Comments
+	// Overlay has the objects of the types printed so far, which are not
+	// printed again. They can be inserted into TargetScope with Commit.
+	Overlay    *types.Scope
+	Comments   Comments
+	FieldOrder FieldOrder
+	Constants  Constants
+	Methods    Methods
+	Sources    Sources
+	Declared   DeclaredTypes
+
+	StructTemplate string
+	FieldTemplate  string
+	EnumTemplate   string
+
+	// DuplicateStrategy decides what to do with the types whose names are
+	// taken by types with different structures in the target scope. The
+	// differences are recorded in Duplicates if they are reported.
+	DuplicateStrategy DuplicateStrategy
+	Duplicates        []Duplicate
+
+	renamed map[*types.TypeName]*types.Named
 }
 
 func (tp *Printer) Print(typeList []*types.Named) (string, error) {
@@ -97,45 +232,144 @@ func (tp *Printer) Print(typeList []*types.Named) (string, error) {
 	sort.SliceStable(typeList, func(i, j int) bool {
 		return typeList[i].Obj().Name() < typeList[j].Obj().Name()
 	})
+	skip, err := tp.checkDuplicates(typeList)
+	if err != nil {
+		return "", err
+	}
 	for _, n := range typeList {
-		// If the type already exists in the package, we assume it's the same
-		// as the one we use here.
-		if tp.TargetScope.Lookup(n.Obj().Name()) != nil {
+		if _, ok := skip[n.Obj()]; ok {
 			continue
 		}
 		switch o := n.Underlying().(type) {
 		case *types.Struct:
-			result, err := tp.printStructType(n.Obj(), o)
+			result, err := tp.printStructType(n, o)
 			if err != nil {
 				return "", errors.Wrapf(err, "cannot print struct type %s", n.Obj().Name())
 			}
 			out += result
-
-		case *types.Basic:
-			result, err := tp.printEnumType(n.Obj(), o)
+		default:
+			result, err := tp.printEnumType(n, o)
 			if err != nil {
-				return "", errors.Wrapf(err, "cannot print struct type %s", n.Obj().Name())
+				return "", errors.Wrapf(err, "cannot print type %s", n.Obj().Name())
 			}
 			out += result
-		default:
-			fmt.Printf("underlying of the type is neither Struct nor Basic, skipping %s\n", n.Obj().Name())
+		}
+		if r, ok := tp.renamed[n.Obj()]; ok {
+			out += renameIdent(tp.printConstants(n)+tp.printMethods(n), n.Obj().Name(), r.Obj().Name())
+			tp.Overlay.Insert(r.Obj())
 			continue
 		}
-		tp.TargetScope.Insert(n.Obj())
+		out += tp.printConstants(n)
+		out += tp.printMethods(n)
+		tp.Overlay.Insert(n.Obj())
 	}
 	return out, nil
 }
 
-// printEnumType assumes that the underlying type is a basic type, which may not
-// be the case all the time.
-// TODO(muvaf): Think about how to handle `type MyEnum MyOtherType`
-func (tp *Printer) printEnumType(name *types.TypeName, b *types.Basic) (string, error) {
+// lookup returns the object with given name among the printed types or the
+// existing objects of the target package.
+func (tp *Printer) lookup(name string) types.Object {
+	if o := tp.Overlay.Lookup(name); o != nil {
+		return o
+	}
+	return tp.TargetScope.Lookup(name)
+}
+
+// Emitted returns the objects of the types printed so far in the order of
+// their names.
+func (tp *Printer) Emitted() []types.Object {
+	names := tp.Overlay.Names()
+	result := make([]types.Object, len(names))
+	for i, n := range names {
+		result[i] = tp.Overlay.Lookup(n)
+	}
+	return result
+}
+
+// Commit inserts the objects of the types printed so far into TargetScope and
+// clears the overlay. It returns an error if any of their names is taken in
+// TargetScope, in which case nothing is inserted.
+func (tp *Printer) Commit() error {
+	emitted := tp.Emitted()
+	for _, o := range emitted {
+		if tp.TargetScope.Lookup(o.Name()) != nil {
+			return errors.Errorf("cannot commit type %s: name is taken in target scope", o.Name())
+		}
+	}
+	for _, o := range emitted {
+		tp.TargetScope.Insert(o)
+	}
+	tp.Overlay = types.NewScope(nil, token.NoPos, token.NoPos, "overlay")
+	return nil
+}
+
+// checkDuplicates returns the types that exist in the target scope with the
+// same structure, which should not be printed again. The ones with different
+// structures are handled according to the DuplicateStrategy.
+func (tp *Printer) checkDuplicates(typeList []*types.Named) (map[*types.TypeName]struct{}, error) {
+	skip := map[*types.TypeName]struct{}{}
+	names := map[string]struct{}{}
+	for _, n := range typeList {
+		names[n.Obj().Name()] = struct{}{}
+	}
+	taken := func(name string) bool {
+		_, ok := names[name]
+		return ok || tp.lookup(name) != nil
+	}
+	for _, n := range typeList {
+		existing := tp.lookup(n.Obj().Name())
+		if existing == nil {
+			continue
+		}
+		diff := structureDiff(existing, n)
+		if len(diff) == 0 {
+			skip[n.Obj()] = struct{}{}
+			continue
+		}
+		d := Duplicate{Name: n.Obj().Name(), Diff: diff}
+		switch tp.DuplicateStrategy {
+		case DuplicateRename:
+			name := uniqueName(n.Obj().Name(), taken)
+			names[name] = struct{}{}
+			r := types.NewNamed(types.NewTypeName(n.Obj().Pos(), n.Obj().Pkg(), name, nil), n.Underlying(), nil)
+			r.SetTypeParams(cloneTypeParams(n.Obj().Pkg(), n.TypeParams()))
+			tp.renamed[n.Obj()] = r
+		case DuplicateReport:
+			tp.Duplicates = append(tp.Duplicates, d)
+			skip[n.Obj()] = struct{}{}
+		default:
+			return nil, errors.New(d.String())
+		}
+	}
+	return skip, nil
+}
+
+// printedName returns the name the type is printed with.
+func (tp *Printer) printedName(n *types.Named) string {
+	if r, ok := tp.renamed[n.Obj()]; ok {
+		return r.Obj().Name()
+	}
+	return n.Obj().Name()
+}
+
+// printEnumType prints the types whose underlying type is not a struct, like
+// enums, maps, slices, funcs or interfaces. A type declared with another named
+// type, like `type MyEnum MyOtherType`, is printed with that type if it's in
+// Declared. Otherwise, it's printed with the underlying type of the other one
+// since go/types doesn't keep the declared type.
+func (tp *Printer) printEnumType(n *types.Named, u types.Type) (string, error) {
+	name := n.Obj()
+	if d, ok := tp.Declared[QualifiedTypePath(name)]; ok {
+		u = d
+	}
 	ei := &EnumTypeTmplInput{
-		Name:           name.Name(),
-		UnderlyingType: b.Name(),
+		TypeTmplData:   tp.typeData(name),
+		Name:           tp.printedName(n),
+		TypeParams:     tp.printTypeParams(n.TypeParams()),
+		UnderlyingType: tp.typeString(u),
 		Comment:        tp.Comments[QualifiedTypePath(name)],
 	}
-	t, err := template.New("enum").Parse(EnumTypeTmpl)
+	t, err := template.New("enum").Parse(tp.EnumTemplate)
 	if err != nil {
 		return "", errors.Wrap(err, "cannot parse template")
 	}
@@ -146,33 +380,34 @@ func (tp *Printer) printEnumType(name *types.TypeName, b *types.Basic) (string,
 	return result.String(), nil
 }
 
-func (tp *Printer) printStructType(name *types.TypeName, s *types.Struct) (string, error) {
+func (tp *Printer) printStructType(n *types.Named, s *types.Struct) (string, error) {
+	name := n.Obj()
 	ti := &StructTypeTmplInput{
-		Name:    name.Name(),
-		Comment: tp.Comments[QualifiedTypePath(name)],
+		TypeTmplData: tp.typeData(name),
+		Name:         tp.printedName(n),
+		TypeParams:   tp.printTypeParams(n.TypeParams()),
+		Comment:      tp.Comments[QualifiedTypePath(name)],
 	}
-	// Field order we get here is not stable but tag & field indexes are coupled.
-	tagMap := make(map[*types.Var]string, s.NumFields())
+	fields := make([]*types.Var, s.NumFields())
+	tags := make([]string, s.NumFields())
 	for i := 0; i < s.NumFields(); i++ {
-		tagMap[s.Field(i)] = s.Tag(i)
+		fields[i] = s.Field(i)
+		tags[i] = s.Tag(i)
 	}
-	fields := make([]*types.Var, len(tagMap))
-	i := 0
-	for f := range tagMap {
-		fields[i] = f
-		i++
-	}
-	sort.SliceStable(fields, func(i, j int) bool {
-		return fields[i].Name() < fields[j].Name()
-	})
-	for _, field := range fields {
+	fields, tags = SortFields(fields, tags, tp.FieldOrder, ti.Markers)
+	for i, field := range fields {
+		fieldName := field.Name()
+		if field.Embedded() {
+			fieldName = ""
+		}
 		fi := &FieldTmplInput{
-			Name:    field.Name(),
-			Type:    tp.Imports.UseType(field.Type().String()),
-			Tag:     tagMap[field],
+			Name:    fieldName,
+			Type:    tp.typeString(field.Type()),
+			Tag:     tags[i],
 			Comment: tp.Comments[QualifiedFieldPath(name, field.Name())],
 		}
-		t, err := template.New("func").Parse(FieldTmpl)
+		fi.Markers = packages.NewCommentMarkersFromText(fi.Comment, packages.CommentPrefix)
+		t, err := template.New("field").Parse(tp.FieldTemplate)
 		if err != nil {
 			return "", errors.Wrap(err, "cannot parse template")
 		}
@@ -183,7 +418,7 @@ func (tp *Printer) printStructType(name *types.TypeName, s *types.Struct) (strin
 		ti.Fields += result.String()
 	}
 	ti.Fields = strings.ReplaceAll(ti.Fields, "\n\n", "\n")
-	t, err := template.New("func").Parse(StructTypeTmpl)
+	t, err := template.New("struct").Parse(tp.StructTemplate)
 	if err != nil {
 		return "", errors.Wrap(err, "cannot parse template")
 	}
@@ -193,3 +428,32 @@ func (tp *Printer) printStructType(name *types.TypeName, s *types.Struct) (strin
 	}
 	return result.String(), nil
 }
+
+// typeData returns the data about the type for the templates.
+func (tp *Printer) typeData(name *types.TypeName) TypeTmplData {
+	d := TypeTmplData{
+		Markers:      packages.NewCommentMarkersFromText(tp.Comments[QualifiedTypePath(name)], packages.CommentPrefix),
+		OriginalName: name.Name(),
+	}
+	if name.Pkg() != nil {
+		d.SourcePackage = name.Pkg().Path()
+	}
+	if src, ok := tp.Sources[QualifiedTypePath(name)]; ok {
+		d.OriginalName = src.Name()
+		d.SourcePackage = src.Pkg().Path()
+	}
+	return d
+}
+
+// printTypeParams returns the type parameter declaration of a generic type,
+// i.e. "[K comparable, V any]", or empty string if it's not generic.
+func (tp *Printer) printTypeParams(tps *types.TypeParamList) string {
+	decl, _ := packages.TypeParamsString(tps, tp.typeString)
+	return decl
+}
+
+// typeString returns the type as it should be written in the file with the
+// packages of the named types it refers to added to the imports.
+func (tp *Printer) typeString(t types.Type) string {
+	return tp.Imports.TypeString(tp.withRenames(t))
+}
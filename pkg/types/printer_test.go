@@ -0,0 +1,290 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"go/format"
+	"go/types"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+	"github.com/muvaf/typewriter/pkg/test"
+)
+
+const shapes = `package test
+
+import "time"
+
+type Base string
+
+type Derived Base
+
+type Labels map[string][]*Labels
+
+type Matrix [3][]Base
+
+type Ref *Base
+
+type Handler func(name string, opts ...Base) (chan<- time.Time, error)
+
+type Stream <-chan map[Base]Labels
+
+type Validator interface {
+	Validate(t time.Time) error
+}
+
+type Spec struct {
+	time.Time
+	Labels   Labels ` + "`json:\"labels\"`" + `
+	Callback func() error
+}
+`
+
+func TestPrinterPrint(t *testing.T) {
+	s := test.ParseString(shapes)
+	var typeList []*types.Named
+	for _, name := range s.Names() {
+		typeList = append(typeList, s.Lookup(name).Type().(*types.Named))
+	}
+	im := packages.NewImports("simple.go", "test")
+	out, err := NewPrinter(im, types.NewScope(nil, 0, 0, ""), WithFieldOrder(OrderDeclaration)).Print(typeList)
+	if err != nil {
+		t.Fatalf("Print(...): unexpected error: %s", err)
+	}
+	got, err := format.Source([]byte("package test\n" + out))
+	if err != nil {
+		t.Fatalf("Print(...): output is not valid Go: %s\n%s", err, out)
+	}
+	// Derived is printed with the underlying type of Base since the types it's
+	// declared with are not given, see TestFlattenerDeclaredTypes.
+	want := `package test
+
+type Base string
+
+type Derived string
+
+type Handler func(name string, opts ...Base) (chan<- time.Time, error)
+
+type Labels map[string][]*Labels
+
+type Matrix [3][]Base
+
+type Ref *Base
+
+type Spec struct {
+	time.Time
+
+	Labels Labels ` + "`json:\"labels\"`" + `
+
+	Callback func() error
+}
+
+type Stream <-chan map[Base]Labels
+
+type Validator interface{ Validate(t time.Time) error }
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("Print(...): -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]string{"time": "time"}, im.Imports); diff != "" {
+		t.Errorf("Print(...): imports: -want, +got:\n%s", diff)
+	}
+}
+
+func TestPrinterTemplates(t *testing.T) {
+	s := test.ParseString(`package test
+
+type Instance struct {
+	Name string
+}
+
+type State string
+`)
+	instance := s.Lookup("Instance").Type().(*types.Named)
+	state := s.Lookup("State").Type().(*types.Named)
+	remote := types.NewTypeName(0, types.NewPackage("example.com/sdk/ec2", "ec2"), "EC2Instance", nil)
+	p := NewPrinter(packages.NewImports("simple.go", "test"), types.NewScope(nil, 0, 0, ""),
+		WithComments(Comments{QualifiedTypePath(instance.Obj()): "// +typewriter:types:kind=Resource"}),
+		WithSources(Sources{QualifiedTypePath(instance.Obj()): remote}),
+		WithStructTemplate(`
+
+// +kubebuilder:object:root=true
+// Copied from {{ .SourcePackage }}.{{ .OriginalName }}
+type {{ .Name }} struct {
+{{ .Fields }}
+}
+
+func ({{ .Name }}) Kind() string { return "{{ index (.Markers.Values "types" "kind") 0 }}" }`),
+		WithFieldTemplate("\n{{ .Name }} {{ .Type }} `json:\"{{ .Name }}\"`"),
+		WithEnumTemplate("\n\n// {{ .Name }} is from {{ .SourcePackage }}.\ntype {{ .Name }} {{ .UnderlyingType }}"),
+	)
+	out, err := p.Print([]*types.Named{instance, state})
+	if err != nil {
+		t.Fatalf("Print(...): unexpected error: %s", err)
+	}
+	got, err := format.Source([]byte("package test\n" + out))
+	if err != nil {
+		t.Fatalf("Print(...): output is not valid Go: %s\n%s", err, out)
+	}
+	want := `package test
+
+// +kubebuilder:object:root=true
+// Copied from example.com/sdk/ec2.EC2Instance
+type Instance struct {
+	Name string ` + "`json:\"Name\"`" + `
+}
+
+func (Instance) Kind() string { return "Resource" }
+
+// State is from simple.go.
+type State string
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("Print(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestPrinterDuplicates(t *testing.T) {
+	existing := test.ParseString(`package test
+
+type Status struct {
+	Code int
+}
+
+type State string
+
+type Labels map[string]string
+`)
+	printed := test.ParseString(`package test
+
+type Instance struct {
+	Status *Status
+	State  State
+}
+
+type Status struct {
+	Code    int
+	Message string
+}
+
+type State string
+`)
+	cases := map[string]struct {
+		strategy   DuplicateStrategy
+		want       string
+		duplicates []Duplicate
+		err        bool
+	}{
+		"Error": {
+			strategy: DuplicateError,
+			err:      true,
+		},
+		"Rename": {
+			strategy: DuplicateRename,
+			want: `package test
+
+type Instance struct {
+	Status *Status2
+
+	State State
+}
+
+type Status2 struct {
+	Code int
+
+	Message string
+}
+`,
+		},
+		"Report": {
+			strategy: DuplicateReport,
+			want: `package test
+
+type Instance struct {
+	Status *Status
+
+	State State
+}
+`,
+			duplicates: []Duplicate{{Name: "Status", Diff: []string{"+Message string"}}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			scope := types.NewScope(nil, 0, 0, "")
+			for _, n := range existing.Names() {
+				scope.Insert(existing.Lookup(n))
+			}
+			var typeList []*types.Named
+			for _, n := range printed.Names() {
+				typeList = append(typeList, printed.Lookup(n).Type().(*types.Named))
+			}
+			p := NewPrinter(packages.NewImports("simple.go", "test"), scope, WithDuplicateStrategy(tc.strategy))
+			out, err := p.Print(typeList)
+			if (err != nil) != tc.err {
+				t.Fatalf("Print(...): unexpected error: %v", err)
+			}
+			if tc.err {
+				return
+			}
+			got, err := format.Source([]byte("package test\n" + out))
+			if err != nil {
+				t.Fatalf("Print(...): output is not valid Go: %s\n%s", err, out)
+			}
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("Print(...): -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.duplicates, p.Duplicates); diff != "" {
+				t.Errorf("Print(...): duplicates: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPrinterOverlay(t *testing.T) {
+	s := test.ParseString(`package test
+
+type Instance struct {
+	Name string
+}
+`)
+	instance := s.Lookup("Instance").Type().(*types.Named)
+	target := types.NewScope(nil, 0, 0, "")
+	p := NewPrinter(packages.NewImports("simple.go", "test"), target)
+	if _, err := p.Print([]*types.Named{instance}); err != nil {
+		t.Fatalf("Print(...): unexpected error: %s", err)
+	}
+	if target.Len() != 0 {
+		t.Errorf("Print(...): target scope is changed: %v", target.Names())
+	}
+	if e := p.Emitted(); len(e) != 1 || e[0] != instance.Obj() {
+		t.Errorf("Emitted(): want only %s, got %v", instance.Obj(), e)
+	}
+	out, err := p.Print([]*types.Named{instance})
+	if err != nil {
+		t.Fatalf("Print(...): unexpected error: %s", err)
+	}
+	if out != "" {
+		t.Errorf("Print(...): printed type is printed again:\n%s", out)
+	}
+	if err := p.Commit(); err != nil {
+		t.Fatalf("Commit(): unexpected error: %s", err)
+	}
+	if target.Lookup("Instance") != instance.Obj() || len(p.Emitted()) != 0 {
+		t.Errorf("Commit(): target scope %v, emitted %v", target.Names(), p.Emitted())
+	}
+}
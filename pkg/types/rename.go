@@ -0,0 +1,59 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// RenameFn returns a new name for the local copy of given remote type whose
+// name is taken by another type. The name is the one the copy would have
+// otherwise, which may be different than the name of the remote type. The
+// returned name must not be taken.
+type RenameFn func(name string, remote *types.TypeName, taken func(name string) bool) string
+
+// PrefixPackageName prefixes the name with the name of the package of the
+// remote type, i.e. Ec2Status for Status in package ec2. A number is added if
+// that's taken as well.
+func PrefixPackageName() RenameFn {
+	return func(name string, remote *types.TypeName, taken func(string) bool) string {
+		if remote.Pkg() != nil && remote.Pkg().Name() != "" {
+			pkgName := remote.Pkg().Name()
+			name = strings.ToUpper(pkgName[:1]) + pkgName[1:] + name
+		}
+		return uniqueName(name, taken)
+	}
+}
+
+// SuffixIndex adds the smallest number starting from 2 that makes the name
+// unique, i.e. Status2.
+func SuffixIndex() RenameFn {
+	return func(name string, _ *types.TypeName, taken func(string) bool) string {
+		return uniqueName(name, taken)
+	}
+}
+
+func uniqueName(name string, taken func(string) bool) string {
+	if !taken(name) {
+		return name
+	}
+	for i := 2; ; i++ {
+		if n := name + strconv.Itoa(i); !taken(n) {
+			return n
+		}
+	}
+}
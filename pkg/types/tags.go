@@ -0,0 +1,242 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"go/types"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// TagEntry is a key and value pair in a struct tag.
+type TagEntry struct {
+	Key   string
+	Value string
+}
+
+// Tag is a struct tag whose entries are kept in order.
+type Tag []TagEntry
+
+// ParseTag parses given struct tag with the conventions of reflect.StructTag,
+// i.e. `json:"name,omitempty" yaml:"name"`.
+func ParseTag(tag string) (Tag, error) {
+	var result Tag
+	for tag != "" {
+		// The loop below follows reflect.StructTag.Lookup.
+		i := 0
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		tag = tag[i:]
+		if tag == "" {
+			break
+		}
+		i = 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			return nil, errors.Errorf("malformed key in tag %q", tag)
+		}
+		key := tag[:i]
+		tag = tag[i+1:]
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			return nil, errors.Errorf("unterminated value of key %s", key)
+		}
+		value, err := strconv.Unquote(tag[:i+1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot unquote value of key %s", key)
+		}
+		result = append(result, TagEntry{Key: key, Value: value})
+		tag = tag[i+1:]
+	}
+	return result, nil
+}
+
+// Get returns the value of the key.
+func (t Tag) Get(key string) (string, bool) {
+	for _, e := range t {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set returns the tag with the value of the key replaced, or appended if the
+// key doesn't exist.
+func (t Tag) Set(key, value string) Tag {
+	result := make(Tag, 0, len(t)+1)
+	found := false
+	for _, e := range t {
+		if e.Key == key {
+			e.Value = value
+			found = true
+		}
+		result = append(result, e)
+	}
+	if !found {
+		result = append(result, TagEntry{Key: key, Value: value})
+	}
+	return result
+}
+
+// Delete returns the tag without the key.
+func (t Tag) Delete(key string) Tag {
+	result := make(Tag, 0, len(t))
+	for _, e := range t {
+		if e.Key != key {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func (t Tag) String() string {
+	entries := make([]string, len(t))
+	for i, e := range t {
+		entries[i] = fmt.Sprintf("%s:%s", e.Key, strconv.Quote(e.Value))
+	}
+	return strings.Join(entries, " ")
+}
+
+// TagFn returns the new tag of the field.
+type TagFn func(field *types.Var, tag Tag) Tag
+
+// NewTagFilter returns a FieldFilter that rewrites the tags of the fields
+// with given functions in order.
+func NewTagFilter(fns ...TagFn) *TagFilter {
+	return &TagFilter{fns: fns}
+}
+
+// TagFilter is a FieldFilter that rewrites the struct tags.
+type TagFilter struct {
+	fns []TagFn
+}
+
+// Filter returns the field with its rewritten tag. Tags that cannot be parsed
+// are left untouched.
+func (tf *TagFilter) Filter(_ FieldOwner, field *types.Var, tag string) (*types.Var, string) {
+	t, err := ParseTag(tag)
+	if err != nil {
+		return field, tag
+	}
+	for _, fn := range tf.fns {
+		t = fn(field, t)
+	}
+	return field, t.String()
+}
+
+// TagValueFn returns the value of a tag key for the field and whether the key
+// should be set.
+type TagValueFn func(field *types.Var, tag Tag) (string, bool)
+
+// AddTag sets the key to the value returned by the function if the tag
+// doesn't have the key already.
+func AddTag(key string, fn TagValueFn) TagFn {
+	return func(field *types.Var, tag Tag) Tag {
+		if _, ok := tag.Get(key); ok {
+			return tag
+		}
+		if v, ok := fn(field, tag); ok {
+			return tag.Set(key, v)
+		}
+		return tag
+	}
+}
+
+// SetTag sets the key to the value returned by the function, overriding the
+// existing value.
+func SetTag(key string, fn TagValueFn) TagFn {
+	return func(field *types.Var, tag Tag) Tag {
+		if v, ok := fn(field, tag); ok {
+			return tag.Set(key, v)
+		}
+		return tag
+	}
+}
+
+// RemoveTags removes the keys from the tag.
+func RemoveTags(keys ...string) TagFn {
+	return func(_ *types.Var, tag Tag) Tag {
+		for _, k := range keys {
+			tag = tag.Delete(k)
+		}
+		return tag
+	}
+}
+
+// MirrorTag adds the key with the value of another key if the tag doesn't
+// have the key already, i.e. yaml with the value of json.
+func MirrorTag(key, from string) TagFn {
+	return AddTag(key, func(_ *types.Var, tag Tag) (string, bool) {
+		return tag.Get(from)
+	})
+}
+
+// CamelCaseName returns the name of the field in camel case, i.e. userID for
+// UserID and httpServer for HTTPServer.
+func CamelCaseName() TagValueFn {
+	return func(field *types.Var, _ Tag) (string, bool) {
+		return camelCase(field.Name()), true
+	}
+}
+
+// OmitEmptyPointers adds omitempty option to the values returned by given
+// function for the pointer fields.
+func OmitEmptyPointers(fn TagValueFn) TagValueFn {
+	return func(field *types.Var, tag Tag) (string, bool) {
+		v, ok := fn(field, tag)
+		if !ok {
+			return "", false
+		}
+		if _, isPtr := field.Type().(*types.Pointer); isPtr {
+			v += ",omitempty"
+		}
+		return v, true
+	}
+}
+
+func camelCase(name string) string {
+	r := []rune(name)
+	upper := 0
+	for upper < len(r) && unicode.IsUpper(r[upper]) {
+		upper++
+	}
+	switch {
+	case upper == 0:
+		return name
+	case upper == 1 || upper == len(r):
+		// Single upper case letter or whole initialism, like Name or ID.
+	case unicode.IsLetter(r[upper]):
+		// The last upper case letter belongs to the next word, like HTTPServer.
+		upper--
+	}
+	for i := 0; i < upper; i++ {
+		r[i] = unicode.ToLower(r[i])
+	}
+	return string(r)
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseTag(t *testing.T) {
+	type want struct {
+		tag Tag
+		err bool
+	}
+	cases := map[string]struct {
+		tag string
+		want
+	}{
+		"Empty": {
+			tag: "",
+		},
+		"Multiple": {
+			tag: `json:"name,omitempty"  xml:"n" protobuf:"bytes,1,opt,name=name"`,
+			want: want{tag: Tag{
+				{Key: "json", Value: "name,omitempty"},
+				{Key: "xml", Value: "n"},
+				{Key: "protobuf", Value: "bytes,1,opt,name=name"},
+			}},
+		},
+		"EscapedQuote": {
+			tag: `description:"a \"quoted\" value"`,
+			want: want{tag: Tag{
+				{Key: "description", Value: `a "quoted" value`},
+			}},
+		},
+		"Malformed": {
+			tag:  `json:name`,
+			want: want{err: true},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseTag(tc.tag)
+			if (err != nil) != tc.want.err {
+				t.Fatalf("ParseTag(...): unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want.tag, got); diff != "" {
+				t.Errorf("ParseTag(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTagFilter(t *testing.T) {
+	pkg := types.NewPackage("test", "test")
+	name := types.NewField(token.NoPos, pkg, "UserID", types.Typ[types.String], false)
+	server := types.NewField(token.NoPos, pkg, "HTTPServer", types.NewPointer(types.Typ[types.String]), false)
+	cases := map[string]struct {
+		field *types.Var
+		tag   string
+		fns   []TagFn
+		want  string
+	}{
+		"AddCamelCase": {
+			field: name,
+			tag:   `xml:"id"`,
+			fns:   []TagFn{AddTag("json", OmitEmptyPointers(CamelCaseName()))},
+			want:  `xml:"id" json:"userID"`,
+		},
+		"AddOmitEmptyForPointers": {
+			field: server,
+			fns:   []TagFn{AddTag("json", OmitEmptyPointers(CamelCaseName()))},
+			want:  `json:"httpServer,omitempty"`,
+		},
+		"AddExisting": {
+			field: name,
+			tag:   `json:"uid"`,
+			fns:   []TagFn{AddTag("json", CamelCaseName())},
+			want:  `json:"uid"`,
+		},
+		"SetExisting": {
+			field: name,
+			tag:   `json:"uid" xml:"id"`,
+			fns:   []TagFn{SetTag("json", CamelCaseName())},
+			want:  `json:"userID" xml:"id"`,
+		},
+		"MirrorAndRemove": {
+			field: name,
+			tag:   `json:"uid,omitempty" xml:"id"`,
+			fns:   []TagFn{MirrorTag("yaml", "json"), RemoveTags("xml")},
+			want:  `json:"uid,omitempty" yaml:"uid,omitempty"`,
+		},
+		"Malformed": {
+			field: name,
+			tag:   `json:uid`,
+			fns:   []TagFn{RemoveTags("json")},
+			want:  `json:uid`,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, got := NewTagFilter(tc.fns...).Filter(FieldOwner{}, tc.field, tc.tag)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Filter(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/muvaf/typewriter/pkg/packages"
+)
+
+// CommentMergeStrategy decides the comment of a generated type or field whose
+// sources have different comments.
+type CommentMergeStrategy int
+
+const (
+	// CommentMergeFirst uses the first non-empty comment.
+	CommentMergeFirst CommentMergeStrategy = iota
+	// CommentMergeJoin uses all distinct comments separated by an empty line.
+	CommentMergeJoin
+)
+
+// MarkerFilter reports whether the marker with given section and key should
+// be kept in the transferred comment.
+type MarkerFilter func(section, key string) bool
+
+// KeepAllMarkers keeps all markers.
+func KeepAllMarkers() MarkerFilter {
+	return func(_, _ string) bool {
+		return true
+	}
+}
+
+// StripAllMarkers removes all markers, leaving only the documentation.
+func StripAllMarkers() MarkerFilter {
+	return func(_, _ string) bool {
+		return false
+	}
+}
+
+// StripMarkers removes the markers with given keys in "<section>:<key>"
+// format, i.e. "field:expr", and keeps the rest.
+func StripMarkers(keys ...string) MarkerFilter {
+	strip := map[string]struct{}{}
+	for _, k := range keys {
+		strip[k] = struct{}{}
+	}
+	return func(section, key string) bool {
+		_, ok := strip[fmt.Sprintf("%s:%s", section, key)]
+		return !ok
+	}
+}
+
+// WithCommentMergeStrategy sets the strategy for sources with different
+// comments. The first non-empty comment is used by default.
+func WithCommentMergeStrategy(s CommentMergeStrategy) CommentTransferOption {
+	return func(ct *CommentTransfer) {
+		ct.MergeStrategy = s
+	}
+}
+
+// WithMarkerFilter sets the filter that decides which markers are carried to
+// the generated objects. All markers are kept by default.
+func WithMarkerFilter(f MarkerFilter) CommentTransferOption {
+	return func(ct *CommentTransfer) {
+		ct.MarkerFilter = f
+	}
+}
+
+type CommentTransferOption func(*CommentTransfer)
+
+func NewCommentTransfer(cc *packages.CommentCache, opts ...CommentTransferOption) *CommentTransfer {
+	ct := &CommentTransfer{
+		Cache:         cc,
+		MergeStrategy: CommentMergeFirst,
+		MarkerFilter:  KeepAllMarkers(),
+	}
+	for _, f := range opts {
+		f(ct)
+	}
+	return ct
+}
+
+// CommentTransfer builds the comments of the generated objects from the
+// comments of the objects they are generated from.
+type CommentTransfer struct {
+	Cache         *packages.CommentCache
+	MergeStrategy CommentMergeStrategy
+	MarkerFilter  MarkerFilter
+}
+
+// Comment returns the comment of given source objects, merged and filtered
+// according to the policy, in the form that Printer expects, i.e. every line
+// starts with "//".
+func (ct *CommentTransfer) Comment(objs ...types.Object) (string, error) {
+	var texts []string
+	for _, o := range objs {
+		c, err := ct.Cache.CommentOf(o)
+		if err != nil {
+			return "", errors.Wrapf(err, "cannot get comment of %s", o.Name())
+		}
+		c = strings.TrimSpace(ct.filterMarkers(c))
+		if c == "" || contains(texts, c) {
+			continue
+		}
+		texts = append(texts, c)
+	}
+	if len(texts) == 0 {
+		return "", nil
+	}
+	text := texts[0]
+	if ct.MergeStrategy == CommentMergeJoin {
+		text = strings.Join(texts, "\n\n")
+	}
+	return commentLines(text), nil
+}
+
+// commentLines returns the text in the form that Printer expects, i.e. every
+// line starts with "//".
+func commentLines(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight("// "+l, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// without returns a copy of the CommentTransfer that strips the marker with
+// given section and key in addition to the ones its filter strips.
+func (ct *CommentTransfer) without(section, key string) *CommentTransfer {
+	keep := ct.MarkerFilter
+	return &CommentTransfer{
+		Cache:         ct.Cache,
+		MergeStrategy: ct.MergeStrategy,
+		MarkerFilter: func(s, k string) bool {
+			return !(s == section && k == key) && keep(s, k)
+		},
+	}
+}
+
+func (ct *CommentTransfer) filterMarkers(c string) string {
+	lines := strings.Split(c, "\n")
+	result := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(l), packages.CommentPrefix) {
+			result = append(result, l)
+			continue
+		}
+		cm := packages.NewCommentMarkersFromText(l, packages.CommentPrefix)
+		keep := true
+		for section, keys := range cm.SectionContents {
+			for key := range keys {
+				keep = keep && ct.MarkerFilter(section, key)
+			}
+		}
+		if keep {
+			result = append(result, l)
+		}
+	}
+	return strings.Join(result, "\n")
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+// Copyright 2021 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"go/types"
+	"reflect"
+	"strings"
+)
+
+// TypeTransformFn returns the new type of the field and whether it should be
+// changed.
+type TypeTransformFn func(field *types.Var, tag string) (types.Type, bool)
+
+// NewTypeTransformFilter returns a FieldFilter that changes the types of the
+// fields with given functions in order.
+func NewTypeTransformFilter(fns ...TypeTransformFn) *TypeTransformFilter {
+	return &TypeTransformFilter{fns: fns}
+}
+
+// TypeTransformFilter is a FieldFilter that changes the types of the fields.
+type TypeTransformFilter struct {
+	fns []TypeTransformFn
+}
+
+func (tf *TypeTransformFilter) Filter(_ FieldOwner, field *types.Var, tag string) (*types.Var, string) {
+	for _, fn := range tf.fns {
+		t, ok := fn(field, tag)
+		if !ok {
+			continue
+		}
+		field = types.NewField(field.Pos(), field.Pkg(), field.Name(), t, field.Embedded())
+	}
+	return field, tag
+}
+
+// FieldPredicate reports whether the field is eligible for a transformation.
+type FieldPredicate func(field *types.Var, tag string) bool
+
+// HasTagOption returns true if the value of the key in the tag has the option,
+// i.e. `json:"name,omitempty"` has omitempty option for json.
+func HasTagOption(key, option string) FieldPredicate {
+	return func(_ *types.Var, tag string) bool {
+		val, ok := reflect.StructTag(tag).Lookup(key)
+		if !ok {
+			return false
+		}
+		for _, o := range strings.Split(val, ",")[1:] {
+			if o == option {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MakePointer turns the type of the fields that satisfy the predicate into a
+// pointer unless it's a pointer, slice or map already.
+func MakePointer(p FieldPredicate) TypeTransformFn {
+	return func(field *types.Var, tag string) (types.Type, bool) {
+		if !p(field, tag) {
+			return nil, false
+		}
+		switch field.Type().Underlying().(type) {
+		case *types.Pointer, *types.Slice, *types.Map:
+			return nil, false
+		}
+		return types.NewPointer(field.Type()), true
+	}
+}
+
+// ReplaceType replaces the types identical to from with to, including the ones
+// that are elements of pointers, slices, arrays and maps, i.e. []*time.Time
+// becomes []*Time. The fields of the replacement type itself are left untouched
+// so that it can wrap the type it replaces.
+func ReplaceType(from, to types.Type) TypeTransformFn {
+	return func(field *types.Var, _ string) (types.Type, bool) {
+		if isFieldOf(field, to) {
+			return nil, false
+		}
+		t := replaceType(field.Type(), from, to)
+		return t, t != field.Type()
+	}
+}
+
+// isFieldOf returns true if the field is declared in the struct of given type.
+func isFieldOf(field *types.Var, t types.Type) bool {
+	if n, ok := t.(*types.Named); ok {
+		t = n.Origin()
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return false
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		if st.Field(i) == field {
+			return true
+		}
+	}
+	return false
+}
+
+func replaceType(t, from, to types.Type) types.Type {
+	if types.Identical(t, from) {
+		return to
+	}
+	switch u := t.(type) {
+	case *types.Pointer:
+		if e := replaceType(u.Elem(), from, to); e != u.Elem() {
+			return types.NewPointer(e)
+		}
+	case *types.Slice:
+		if e := replaceType(u.Elem(), from, to); e != u.Elem() {
+			return types.NewSlice(e)
+		}
+	case *types.Array:
+		if e := replaceType(u.Elem(), from, to); e != u.Elem() {
+			return types.NewArray(e, u.Len())
+		}
+	case *types.Map:
+		k := replaceType(u.Key(), from, to)
+		e := replaceType(u.Elem(), from, to)
+		if k != u.Key() || e != u.Elem() {
+			return types.NewMap(k, e)
+		}
+	}
+	return t
+}
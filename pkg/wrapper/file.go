@@ -21,6 +21,9 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"text/template"
 
 	"github.com/pkg/errors"
@@ -52,6 +55,15 @@ func WithGenStatement(s string) FileOption {
 	}
 }
 
+// WithLocalPrefix sets the path prefix of the packages that are imported in a
+// separate group after the third-party ones, which is usually the path of the
+// Go module the file is in.
+func WithLocalPrefix(p string) FileOption {
+	return func(f *File) {
+		f.LocalPrefix = p
+	}
+}
+
 type FileOption func(*File)
 
 func NewFile(pkgPath, pkgName, tmpl string, opts ...FileOption) *File {
@@ -74,20 +86,19 @@ type File struct {
 	PackageName   string
 	LinterEnabled bool
 	Imports       *packages.Imports
+	LocalPrefix   string
 }
 
 // Wrap writes the objects to the file one by one.
 func (f *File) Wrap(input map[string]interface{}) ([]byte, error) {
-	importStatements := ""
-	for p, a := range f.Imports.Imports {
-		// We always use an alias because package name does not necessarily equal
-		// to that the last word in the path, hence it's not completely safe to
-		// not use an alias even though there is no conflict.
-		importStatements += fmt.Sprintf("%s \"%s\"\n", a, p)
-	}
-	header, err := ioutil.ReadFile(f.HeaderPath)
-	if err != nil {
-		return nil, errors.Wrap(err, "cannot read header file")
+	importStatements := f.importBlock()
+	header := []byte{}
+	if f.HeaderPath != "" {
+		h, err := ioutil.ReadFile(f.HeaderPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read header file")
+		}
+		header = h
 	}
 	values := map[string]interface{}{
 		"Header":       string(header),
@@ -121,6 +132,60 @@ func (f *File) Wrap(input map[string]interface{}) ([]byte, error) {
 	return result.Bytes(), nil
 }
 
+// importBlock returns the imports grouped as standard library, third-party
+// and local packages, each sorted by path. Aliases are used only if they're
+// different from the names of the packages.
+func (f *File) importBlock() string {
+	groups := make([][]string, 3)
+	for p := range f.Imports.Imports {
+		i := 1
+		switch {
+		case !strings.Contains(strings.Split(p, "/")[0], "."):
+			i = 0
+		case f.LocalPrefix != "" && (p == f.LocalPrefix || strings.HasPrefix(p, f.LocalPrefix+"/")):
+			i = 2
+		}
+		groups[i] = append(groups[i], p)
+	}
+	var blocks []string
+	for i, g := range groups {
+		if len(g) == 0 {
+			continue
+		}
+		sort.Strings(g)
+		lines := make([]string, len(g))
+		for j, p := range g {
+			a := f.Imports.Imports[p]
+			lines[j] = fmt.Sprintf("\t%s \"%s\"", a, p)
+			if name, ok := packageName(f.Imports, p, i == 0); ok && name == a {
+				lines[j] = fmt.Sprintf("\t\"%s\"", p)
+			}
+		}
+		blocks = append(blocks, strings.Join(lines, "\n"))
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+var versionSuffix = regexp.MustCompile(`^v[0-9]+$`)
+
+// packageName returns the name of the package in given path if it's known.
+// The names of the standard library packages are the last elements of their
+// paths, except the major version suffixes, but it's not guaranteed for the
+// others.
+func packageName(im *packages.Imports, path string, stdlib bool) (string, bool) {
+	if name, ok := im.Names[path]; ok {
+		return name, true
+	}
+	if !stdlib {
+		return "", false
+	}
+	words := strings.Split(path, "/")
+	if len(words) > 1 && versionSuffix.MatchString(words[len(words)-1]) {
+		return words[len(words)-2], true
+	}
+	return words[len(words)-1], true
+}
+
 // Write wraps the file with given input and writes it to the file system.
 func (f *File) Write(name string, input map[string]interface{}, perm os.FileMode) error {
 	if err := os.MkdirAll(filepath.Dir(name), perm); err != nil {
@@ -0,0 +1,142 @@
+// Copyright 2022 Muvaffak Onus
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrapper
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/muvaf/typewriter/internal/templates"
+)
+
+func TestFileImportBlock(t *testing.T) {
+	cases := map[string]struct {
+		pkgs  []*types.Package
+		paths []string
+		want  string
+	}{
+		"Empty": {
+			want: "",
+		},
+		"Groups": {
+			pkgs: []*types.Package{
+				types.NewPackage("github.com/muvaf/typewriter/examples/producer/db", "db"),
+				types.NewPackage("strings", "strings"),
+				types.NewPackage("github.com/pkg/errors", "errors"),
+				types.NewPackage("fmt", "fmt"),
+				types.NewPackage("github.com/muvaf/typewriter/examples/producer/app", "app"),
+				types.NewPackage("github.com/google/go-cmp/cmp", "cmp"),
+			},
+			want: `	"fmt"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/muvaf/typewriter/examples/producer/app"
+	"github.com/muvaf/typewriter/examples/producer/db"`,
+		},
+		"Alias": {
+			pkgs: []*types.Package{
+				types.NewPackage("k8s.io/api/core/v1", "v1"),
+				types.NewPackage("k8s.io/api/apps/v1", "v1"),
+			},
+			want: `	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/api/core/v1"`,
+		},
+		"UnknownName": {
+			// Only the path is known, so the alias is kept even though it's
+			// the last element of the path.
+			paths: []string{"gopkg.in/yaml.v3", "github.com/muvaf/typewriterx/api"},
+			want: `	api "github.com/muvaf/typewriterx/api"
+	yamlv3 "gopkg.in/yaml.v3"`,
+		},
+		"StdlibVersionSuffix": {
+			pkgs:  []*types.Package{types.NewPackage("math/rand/v2", "rand")},
+			paths: []string{"encoding/json"},
+			want: `	"encoding/json"
+	"math/rand/v2"`,
+		},
+		"StdlibVersionSuffixAlias": {
+			// The alias is assumed to be the last element of the path, which
+			// isn't the name of the package, so it's kept.
+			paths: []string{"math/rand/v2"},
+			want:  `	v2 "math/rand/v2"`,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := NewFile("github.com/muvaf/typewriter/examples/producer", "producer", "", WithLocalPrefix("github.com/muvaf/typewriter"))
+			for _, p := range tc.pkgs {
+				f.Imports.Qualifier()(p)
+			}
+			for _, p := range tc.paths {
+				f.Imports.UsePackage(p)
+			}
+			if diff := cmp.Diff(tc.want, f.importBlock()); diff != "" {
+				t.Errorf("importBlock(): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFileWrapImports(t *testing.T) {
+	cases := map[string]struct {
+		paths []string
+		want  string
+	}{
+		"NoImports": {
+			want: `
+
+// Code generated by typewriter. DO NOT EDIT.
+
+package producer
+
+
+func Generate() {}`,
+		},
+		"Imports": {
+			paths: []string{"fmt"},
+			want: `
+
+// Code generated by typewriter. DO NOT EDIT.
+
+package producer
+
+import (
+	"fmt"
+)
+
+func Generate() {}`,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := NewFile("github.com/muvaf/typewriter/examples/producer", "producer", templates.ProducersTemplate)
+			for _, p := range tc.paths {
+				f.Imports.UsePackage(p)
+			}
+			got, err := f.Wrap(map[string]interface{}{"Producers": "func Generate() {}"})
+			if err != nil {
+				t.Fatalf("Wrap(...): unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("Wrap(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}